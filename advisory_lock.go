@@ -0,0 +1,126 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdvisoryLockKey derives a PostgreSQL advisory lock key from a string.
+// The key is a 64-bit FNV-1a hash, so it is stable across processes and releases.
+//
+// Example:
+//
+//	key := sqlreader.AdvisoryLockKey("cron:send_digest")
+func AdvisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// WithAdvisoryLock runs fn while holding the advisory lock derived from key,
+// waiting until the lock becomes available.
+//
+// On a pool connector the lock is a session-level lock taken on a connection
+// that stays pinned until fn returns and the lock is released. On a transaction
+// connector, or when ctx carries a transaction stored with WithTx, the lock is
+// taken with pg_advisory_xact_lock and released when the transaction ends.
+//
+// Example:
+//
+//	err := conn.WithAdvisoryLock(ctx, "cron:send_digest", func(ctx context.Context) error {
+//	    return sendDigest(ctx)
+//	})
+func (c *Connector) WithAdvisoryLock(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	_, err := c.advisoryLock(ctx, key, false, fn)
+	return err
+}
+
+// TryAdvisoryLock runs fn if the advisory lock derived from key can be acquired
+// immediately. It reports whether the lock was acquired; when it wasn't, fn is
+// not called. Locks are taken and released as described for WithAdvisoryLock.
+//
+// Example:
+//
+//	// Only one instance runs the job, the others skip it
+//	ran, err := conn.TryAdvisoryLock(ctx, "cron:send_digest", sendDigest)
+func (c *Connector) TryAdvisoryLock(ctx context.Context, key string, fn func(ctx context.Context) error) (bool, error) {
+	return c.advisoryLock(ctx, key, true, fn)
+}
+
+// advisoryLock acquires the lock on the appropriate connection and runs fn.
+func (c *Connector) advisoryLock(ctx context.Context, key string, try bool, fn func(ctx context.Context) error) (bool, error) {
+	lockKey := AdvisoryLockKey(key)
+
+	tx, isTx := c.db.(pgx.Tx)
+	if !isTx {
+		tx, isTx = TxFromContext(ctx)
+	}
+	if isTx {
+		acquired, err := lockAdvisory(ctx, tx, lockKey, try, "pg_advisory_xact_lock", "pg_try_advisory_xact_lock")
+		if err != nil || !acquired {
+			return false, wrapLockError(key, err)
+		}
+		return true, fn(ctx)
+	}
+
+	pool, ok := c.db.(*pgxpool.Pool)
+	if !ok {
+		return false, fmt.Errorf("unexpected connection type, expected *pgxpool.Pool")
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquiring connection for advisory lock %s: %w", key, err)
+	}
+
+	acquired, err := lockAdvisory(ctx, conn, lockKey, try, "pg_advisory_lock", "pg_try_advisory_lock")
+	if err != nil || !acquired {
+		conn.Release()
+		return false, wrapLockError(key, err)
+	}
+
+	return true, unlockAdvisory(ctx, conn, key, lockKey, fn(ctx))
+}
+
+// unlockAdvisory releases a session-level advisory lock and the connection
+// holding it, returning fnErr or the error from releasing the lock.
+func unlockAdvisory(ctx context.Context, conn *pgxpool.Conn, key string, lockKey int64, fnErr error) error {
+	// Unlock even if ctx was cancelled, so that the lock never outlives fn
+	if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+		// Closing the session is the only other way to release the lock
+		conn.Hijack().Close(context.WithoutCancel(ctx))
+		if fnErr == nil {
+			return fmt.Errorf("releasing advisory lock %s: %w", key, err)
+		}
+		return fnErr
+	}
+	conn.Release()
+
+	return fnErr
+}
+
+// lockAdvisory calls the blocking or the try variant of an advisory lock function.
+func lockAdvisory(ctx context.Context, db DB, lockKey int64, try bool, lockFunc, tryFunc string) (bool, error) {
+	if !try {
+		_, err := db.Exec(ctx, "SELECT "+lockFunc+"($1)", lockKey)
+		return err == nil, err
+	}
+
+	var acquired bool
+	if err := db.QueryRow(ctx, "SELECT "+tryFunc+"($1)", lockKey).Scan(&acquired); err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+// wrapLockError adds the lock name to an error from acquiring a lock.
+func wrapLockError(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("acquiring advisory lock %s: %w", key, err)
+}
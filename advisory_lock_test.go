@@ -0,0 +1,66 @@
+package sqlreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test that lock keys are stable across releases
+func TestAdvisoryLockKey(t *testing.T) {
+	// 64-bit FNV-1a hash of "migrations"
+	if key := AdvisoryLockKey("migrations"); key != -590408363552450360 {
+		t.Errorf("Unexpected key %d", key)
+	}
+	if AdvisoryLockKey("a") != AdvisoryLockKey("a") {
+		t.Error("Expected equal names to produce equal keys")
+	}
+	if AdvisoryLockKey("a") == AdvisoryLockKey("b") {
+		t.Error("Expected different names to produce different keys")
+	}
+}
+
+// Test transaction-level advisory locks
+func TestConnector_AdvisoryLockInTx(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	connector := &Connector{db: mock, reader: &SQLReader{}}
+	key := AdvisoryLockKey("cron:digest")
+
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").
+		WithArgs(key).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock").
+		WithArgs(key).
+		WillReturnRows(pgxmock.NewRows([]string{"acquired"}).AddRow(false))
+
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		return nil
+	}
+
+	if err := connector.WithAdvisoryLock(context.Background(), "cron:digest", fn); err != nil {
+		t.Errorf("WithAdvisoryLock returned an error: %v", err)
+	}
+
+	acquired, err := connector.TryAdvisoryLock(context.Background(), "cron:digest", fn)
+	if err != nil {
+		t.Errorf("TryAdvisoryLock returned an error: %v", err)
+	}
+	if acquired {
+		t.Error("Expected TryAdvisoryLock not to acquire a held lock")
+	}
+	if calls != 1 {
+		t.Errorf("Expected fn to run once, ran %d times", calls)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
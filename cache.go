@@ -0,0 +1,412 @@
+package sqlreader
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Cache stores the results of named queries annotated with "-- cache:".
+//
+// Implementations must be safe for concurrent use. A failing cache should
+// behave as if the entry were missing; query results are always correct
+// without it. NewLRUCache returns the default in-memory implementation.
+type Cache interface {
+	// Get returns the result stored under key, if present and not expired.
+	Get(ctx context.Context, key string) (*CachedResult, bool)
+
+	// Set stores a result under key for the given duration, associated with tags.
+	Set(ctx context.Context, key string, result *CachedResult, ttl time.Duration, tags []string)
+
+	// Invalidate removes every entry associated with any of the given tags.
+	Invalidate(ctx context.Context, tags ...string)
+}
+
+// CachedResult holds the rows returned by a cached query.
+//
+// Rows keep the column values in the wire format described by Fields, so
+// scanning a cached result behaves exactly like scanning the original rows.
+type CachedResult struct {
+	Fields []pgconn.FieldDescription
+	Rows   [][][]byte
+}
+
+// WithCache enables result caching for read queries annotated with
+// "-- cache: <duration>".
+//
+// Cached entries are keyed by query name and arguments, as encoded by pgx's
+// default type map; calls with arguments it can't encode bypass the cache.
+// Entries are tagged with the tables listed in the query's "-- tags:"
+// annotation. Executing a query annotated with "-- invalidates:" purges every
+// entry carrying one of its tags.
+// Cached queries without tags are only refreshed when their TTL expires.
+// Queries running in a transaction bypass the cache. Invalidations in a
+// transaction started with Connector.Begin take effect when it commits.
+//
+// Cached rows are decoded with pgx's default type map, not the connection's.
+// Results containing enums, composite or other types registered on the
+// connection, for example in AfterConnect, must be scanned into strings or
+// []byte, or left uncached.
+//
+//	-- name: list_countries
+//	-- cache: 5m
+//	-- tags: countries
+//	SELECT code, name FROM countries ORDER BY name
+//
+//	-- name: rename_country
+//	-- invalidates: countries
+//	UPDATE countries SET name = $2 WHERE code = $1
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithCache(sqlreader.NewLRUCache(1000)))
+func WithCache(cache Cache) Option {
+	return func(r *SQLReader) {
+		r.cache = cache
+	}
+}
+
+// cacheTTL returns the caching duration declared by the query's cache annotation.
+func (m queryMeta) cacheTTL() (time.Duration, bool) {
+	value, ok := m.annotation("cache")
+	if !ok {
+		return 0, false
+	}
+
+	ttl, err := parseCacheTTL(value)
+	if err != nil {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// parseCacheTTL parses the value of a cache annotation, which must be a
+// positive duration such as "30s" or "5m".
+func parseCacheTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache duration %q, expected a duration such as 30s or 5m", value)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid cache duration %q, must be positive", value)
+	}
+	return ttl, nil
+}
+
+// tagList returns the comma-separated values of the named annotation.
+func (m queryMeta) tagList(key string) []string {
+	value, ok := m.annotation(key)
+	if !ok {
+		return nil
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// cacheKey derives the cache key for a query name and its arguments. Each
+// argument is encoded as pgx sends it, in binary with pgx's default type map,
+// and prefixed with its type OID and length, so different arguments never
+// share a key. Pointers are keyed by the value they point to and nil values
+// as NULL.
+//
+// It reports false for arguments the type map can't encode, such as
+// pgx.NamedArgs or types registered on the connection; such queries bypass
+// the cache.
+func cacheKey(name string, args []interface{}) (string, bool) {
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	h := sha256.New()
+	var header [8]byte
+	for _, arg := range args {
+		var oid uint32
+		var buf []byte
+		if !isNil(arg) {
+			t, ok := m.TypeForValue(arg)
+			if !ok {
+				return "", false
+			}
+			encoded, err := m.Encode(t.OID, pgtype.BinaryFormatCode, arg, nil)
+			if err != nil {
+				return "", false
+			}
+			oid, buf = t.OID, encoded
+		}
+
+		length := int32(-1)
+		if buf != nil {
+			length = int32(len(buf))
+		}
+		binary.BigEndian.PutUint32(header[:4], oid)
+		binary.BigEndian.PutUint32(header[4:], uint32(length))
+		h.Write(header[:])
+		h.Write(buf)
+	}
+	return name + ":" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+// isNil reports whether v is nil or a nil pointer, which pgx sends as NULL.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return true
+		}
+		rv = rv.Elem()
+	}
+	return false
+}
+
+// collectRows reads all remaining rows into a CachedResult and closes rows.
+func collectRows(rows pgx.Rows) (*CachedResult, error) {
+	result := &CachedResult{
+		Fields: append([]pgconn.FieldDescription(nil), rows.FieldDescriptions()...),
+	}
+	for rows.Next() {
+		raw := rows.RawValues()
+		row := make([][]byte, len(raw))
+		for i, value := range raw {
+			if value != nil {
+				// pgx reuses the raw buffers between rows
+				row[i] = append([]byte{}, value...)
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	rows.Close()
+	return result, rows.Err()
+}
+
+// typeMaps pools pgtype maps used to scan cached rows, as a map is
+// not safe for concurrent use and expensive to create.
+var typeMaps = sync.Pool{
+	New: func() interface{} { return pgtype.NewMap() },
+}
+
+// cachedRows replays a CachedResult through the pgx.Rows and pgx.Row interfaces.
+type cachedRows struct {
+	result *CachedResult
+	pos    int
+	closed bool
+	err    error
+}
+
+// newCachedRows creates rows positioned before the first cached row.
+func newCachedRows(result *CachedResult) *cachedRows {
+	return &cachedRows{result: result}
+}
+
+func (r *cachedRows) Close() {
+	r.closed = true
+}
+
+func (r *cachedRows) Err() error {
+	return r.err
+}
+
+func (r *cachedRows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("SELECT %d", len(r.result.Rows)))
+}
+
+func (r *cachedRows) FieldDescriptions() []pgconn.FieldDescription {
+	return r.result.Fields
+}
+
+func (r *cachedRows) Next() bool {
+	if r.closed || r.err != nil || r.pos >= len(r.result.Rows) {
+		r.closed = true
+		return false
+	}
+	r.pos++
+	return true
+}
+
+// Scan scans the current row into dest. When called before Next, as pgx.Row
+// does, it scans the first row and returns pgx.ErrNoRows if there is none.
+func (r *cachedRows) Scan(dest ...interface{}) error {
+	if r.pos == 0 {
+		if !r.Next() {
+			return pgx.ErrNoRows
+		}
+		defer r.Close()
+	}
+
+	raw := r.result.Rows[r.pos-1]
+	if len(dest) != len(raw) {
+		return fmt.Errorf("number of field descriptions must equal number of destinations, got %d and %d", len(raw), len(dest))
+	}
+
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		fd := r.result.Fields[i]
+		if err := m.Scan(fd.DataTypeOID, fd.Format, raw[i], d); err != nil {
+			r.err = fmt.Errorf("can't scan into dest[%d]: %w", i, err)
+			return r.err
+		}
+	}
+
+	return nil
+}
+
+func (r *cachedRows) Values() ([]interface{}, error) {
+	raw := r.result.Rows[r.pos-1]
+	values := make([]interface{}, len(raw))
+
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	for i, value := range raw {
+		if value == nil {
+			continue
+		}
+		fd := r.result.Fields[i]
+		if t, ok := m.TypeForOID(fd.DataTypeOID); ok {
+			decoded, err := t.Codec.DecodeValue(m, fd.DataTypeOID, fd.Format, value)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = decoded
+		} else if fd.Format == pgtype.TextFormatCode {
+			values[i] = string(value)
+		} else {
+			values[i] = append([]byte{}, value...)
+		}
+	}
+
+	return values, nil
+}
+
+func (r *cachedRows) RawValues() [][]byte {
+	return r.result.Rows[r.pos-1]
+}
+
+func (r *cachedRows) Conn() *pgx.Conn {
+	return nil
+}
+
+// lruCache is the default in-memory Cache with least-recently-used eviction.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List               // Front is the most recently used entry
+	entries  map[string]*list.Element // Values are *lruEntry
+	tags     map[string]map[string]struct{}
+}
+
+// lruEntry is a single cached result.
+type lruEntry struct {
+	key     string
+	result  *CachedResult
+	expires time.Time
+	tags    []string
+}
+
+// NewLRUCache creates an in-memory Cache holding at most capacity results.
+// The least recently used result is evicted when the cache is full.
+func NewLRUCache(capacity int) Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *lruCache) Get(ctx context.Context, key string) (*CachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *lruCache) Set(ctx context.Context, key string, result *CachedResult, ttl time.Duration, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	entry := &lruEntry{key: key, result: result, expires: time.Now().Add(ttl), tags: tags}
+	c.entries[key] = c.order.PushFront(entry)
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lruCache) Invalidate(ctx context.Context, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if elem, ok := c.entries[key]; ok {
+				c.remove(elem)
+			}
+		}
+		delete(c.tags, tag)
+	}
+}
+
+// remove deletes an entry and its tag references. The caller must hold c.mu.
+func (c *lruCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*lruEntry)
+	delete(c.entries, entry.key)
+	for _, tag := range entry.tags {
+		if keys := c.tags[tag]; keys != nil {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package sqlreader
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test LRU eviction, expiry and tag invalidation
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(2)
+	result := &CachedResult{}
+
+	cache.Set(ctx, "a", result, time.Minute, []string{"users"})
+	cache.Set(ctx, "b", result, time.Minute, []string{"posts"})
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", result, time.Minute, []string{"users"})
+
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Error("Expected recently used entry to be kept")
+	}
+
+	cache.Invalidate(ctx, "users")
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Error("Expected entry tagged users to be invalidated")
+	}
+	if _, ok := cache.Get(ctx, "c"); ok {
+		t.Error("Expected entry tagged users to be invalidated")
+	}
+
+	cache.Set(ctx, "d", result, -time.Second, nil)
+	if _, ok := cache.Get(ctx, "d"); ok {
+		t.Error("Expected expired entry to be missing")
+	}
+}
+
+// Test that annotated queries are served from the cache until invalidated
+func TestQueryLoader_Cache(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	qs := &queryStore{queries: make(map[string]string)}
+	err = qs.parseQueries("sql/countries.sql", `-- name: get_country
+-- cache: 1m
+-- tags: countries
+SELECT id, population FROM countries WHERE code = $1
+
+-- name: set_population
+-- invalidates: countries
+UPDATE countries SET population = $2 WHERE code = $1`)
+	if err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+
+	loader := &queryLoader{db: nonTxConn{mock}, querier: qs, cache: NewLRUCache(10)}
+
+	// pgxmock reports raw values JSON-encoded, which matches the text format of integers
+	columns := []pgconn.FieldDescription{
+		{Name: "id", DataTypeOID: pgtype.Int4OID, Format: pgtype.TextFormatCode},
+		{Name: "population", DataTypeOID: pgtype.Int8OID, Format: pgtype.TextFormatCode},
+	}
+	mock.ExpectQuery("SELECT id, population FROM countries").
+		WithArgs("NL").
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(columns...).AddRow(1, 17000000))
+	mock.ExpectExec("UPDATE countries").
+		WithArgs("NL", 18000000).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("SELECT id, population FROM countries").
+		WithArgs("NL").
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(columns...).AddRow(1, 18000000))
+
+	getPopulation := func() int64 {
+		var id int
+		var population int64
+		err := loader.queryRow(context.Background(), "get_country", func(row pgx.Row) error {
+			return row.Scan(&id, &population)
+		}, "NL")
+		if err != nil {
+			t.Fatalf("queryRow returned an error: %v", err)
+		}
+		return population
+	}
+
+	if population := getPopulation(); population != 17000000 {
+		t.Errorf("Expected 17000000, got %d", population)
+	}
+	if population := getPopulation(); population != 17000000 {
+		t.Errorf("Expected cached 17000000, got %d", population)
+	}
+
+	if err := loader.exec(context.Background(), "set_population", "NL", 18000000); err != nil {
+		t.Fatalf("exec returned an error: %v", err)
+	}
+
+	var populations []int64
+	err = loader.queryRows(context.Background(), "get_country", func(rows pgx.Rows) error {
+		for rows.Next() {
+			var id int
+			var population int64
+			if err := rows.Scan(&id, &population); err != nil {
+				return err
+			}
+			populations = append(populations, population)
+		}
+		return nil
+	}, "NL")
+	if err != nil {
+		t.Fatalf("queryRows returned an error: %v", err)
+	}
+	if len(populations) != 1 || populations[0] != 18000000 {
+		t.Errorf("Expected fresh result after invalidation, got %v", populations)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that invalidations in a transaction started with Begin wait for the commit
+func TestConnector_BeginDefersInvalidation(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	qs := &queryStore{queries: make(map[string]string)}
+	err = qs.parseQueries("sql/countries.sql", `-- name: set_population
+-- invalidates: countries
+UPDATE countries SET population = $2 WHERE code = $1`)
+	if err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+
+	ctx := context.Background()
+	cache := NewLRUCache(10)
+	reader := &SQLReader{queries: qs, cache: cache}
+	conn := reader.Connect(beginnerConn{DB: mock, beginner: mock})
+
+	cached := func() bool {
+		_, ok := cache.Get(ctx, "get_country")
+		return ok
+	}
+
+	for _, commit := range []bool{false, true} {
+		cache.Set(ctx, "get_country", &CachedResult{}, time.Minute, []string{"countries"})
+
+		mock.ExpectBegin()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE countries").
+			WithArgs("NL", 18000000).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectCommit()
+		if commit {
+			mock.ExpectCommit()
+		} else {
+			mock.ExpectRollback()
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Begin returned an error: %v", err)
+		}
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Begin returned an error: %v", err)
+		}
+		if err := conn.Exec(WithTx(ctx, savepoint), "set_population", "NL", 18000000); err != nil {
+			t.Fatalf("Exec returned an error: %v", err)
+		}
+		if err := savepoint.Commit(ctx); err != nil {
+			t.Fatalf("Commit returned an error: %v", err)
+		}
+		if !cached() {
+			t.Error("Expected entry to survive until the transaction commits")
+		}
+
+		if commit {
+			err = tx.Commit(ctx)
+		} else {
+			err = tx.Rollback(ctx)
+		}
+		if err != nil {
+			t.Fatalf("Ending transaction returned an error: %v", err)
+		}
+		if cached() == commit {
+			t.Errorf("Commit %v: expected entry cached %v", commit, !commit)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that malformed cache durations are reported when parsing queries
+func TestParseQueries_CacheDuration(t *testing.T) {
+	for _, value := range []string{"30", "soon", "-5m", "0s"} {
+		qs := &queryStore{}
+		err := qs.parseQueries("sql/countries.sql", "-- name: list_countries\n-- cache: "+value+"\nSELECT code FROM countries")
+		if err == nil || !strings.Contains(err.Error(), "query list_countries: invalid cache duration") {
+			t.Errorf("Expected an invalid cache duration error for %q, got %v", value, err)
+		}
+	}
+}
+
+// Test that pointer arguments share the cache key of their values and nil
+// pointers that of NULL
+func TestCacheKey_Pointers(t *testing.T) {
+	code, other := "NL", "NL"
+	var missing *string
+
+	key := func(args ...interface{}) string {
+		t.Helper()
+		k, ok := cacheKey("q", args)
+		if !ok {
+			t.Fatalf("Expected arguments %v to be cacheable", args)
+		}
+		return k
+	}
+
+	if key(&code) != key(&other) {
+		t.Error("Expected pointers to equal values to share a key")
+	}
+	if key(&code) != key("NL") {
+		t.Error("Expected a pointer to share the key of its value")
+	}
+	if key(missing) != key(nil) {
+		t.Error("Expected a nil pointer to share the key of NULL")
+	}
+	if key(missing) == key("") {
+		t.Error("Expected NULL and an empty string to have different keys")
+	}
+}
+
+// Test that distinct arguments never share a cache key
+func TestCacheKey_Collisions(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []interface{}
+	}{
+		{name: "slice elements", a: []interface{}{[]string{"a b"}}, b: []interface{}{[]string{"a", "b"}}},
+		{name: "argument boundaries", a: []interface{}{"a\x00string:b"}, b: []interface{}{"a", "b"}},
+		{name: "split strings", a: []interface{}{"ab", "c"}, b: []interface{}{"a", "bc"}},
+		{name: "integer widths", a: []interface{}{int32(1)}, b: []interface{}{int64(1)}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, okA := cacheKey("q", tc.a)
+			b, okB := cacheKey("q", tc.b)
+			if !okA || !okB {
+				t.Fatalf("Expected both argument lists to be cacheable")
+			}
+			if a == b {
+				t.Errorf("Expected %v and %v to have different keys", tc.a, tc.b)
+			}
+		})
+	}
+}
+
+// Test that arguments the default type map can't encode bypass the cache
+func TestCacheKey_Unencodable(t *testing.T) {
+	if _, ok := cacheKey("q", []interface{}{pgx.NamedArgs{"id": 1}}); ok {
+		t.Error("Expected named arguments to bypass the cache")
+	}
+	if _, ok := cacheKey("q", []interface{}{struct{ ID int }{1}}); ok {
+		t.Error("Expected an unknown struct to bypass the cache")
+	}
+}
+
+// beginnerConn is a connection that starts transactions without being one,
+// like a pool.
+type beginnerConn struct {
+	DB
+	beginner txBeginner
+}
+
+func (c beginnerConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return c.beginner.Begin(ctx)
+}
@@ -0,0 +1,108 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// cacheTx is a transaction started by Connector.Begin. Statements annotated
+// with "-- invalidates:" that run in it record their tags instead of purging
+// the cache, and the tags are invalidated once the transaction commits.
+// Purging earlier would let a concurrent read cache the pre-commit data again.
+type cacheTx struct {
+	pgx.Tx
+	cache  Cache
+	parent *cacheTx // Enclosing transaction of a savepoint
+
+	mu   sync.Mutex
+	tags []string
+}
+
+// Begin starts a savepoint whose tags are handed to t when it commits.
+func (t *cacheTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheTx{Tx: tx, cache: t.cache, parent: t}, nil
+}
+
+// Commit commits the transaction and invalidates the recorded tags. The tags
+// are invalidated even if Commit fails, as the outcome of a failed commit
+// can't always be known.
+func (t *cacheTx) Commit(ctx context.Context) error {
+	err := t.Tx.Commit(ctx)
+
+	tags := t.takeTags()
+	switch {
+	case len(tags) == 0:
+	case t.parent != nil:
+		t.parent.addTags(tags...)
+	case t.cache != nil:
+		t.cache.Invalidate(ctx, tags...)
+	}
+	return err
+}
+
+// Rollback rolls the transaction back and drops the recorded tags.
+func (t *cacheTx) Rollback(ctx context.Context) error {
+	t.takeTags()
+	return t.Tx.Rollback(ctx)
+}
+
+// addTags records tags to invalidate when the transaction commits.
+func (t *cacheTx) addTags(tags ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tags = append(t.tags, tags...)
+}
+
+// takeTags returns and clears the recorded tags.
+func (t *cacheTx) takeTags() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tags := t.tags
+	t.tags = nil
+	return tags
+}
+
+// Begin starts a transaction on the connector's connection, or a savepoint if
+// the connector is bound to a transaction.
+//
+// Cache invalidations of the statements run in the returned transaction,
+// through ConnectTx or WithTx, are deferred until it commits. Statements in
+// transactions started elsewhere purge the cache right away, so a concurrent
+// read may cache data that is later rolled back or replaced by the commit
+// until the entry expires.
+//
+// Example:
+//
+//	tx, err := conn.Begin(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer tx.Rollback(ctx)
+//
+//	ctx = sqlreader.WithTx(ctx, tx)
+//	if err := conn.Exec(ctx, "rename_country", "NL", "Netherlands"); err != nil {
+//	    return err
+//	}
+//	return tx.Commit(ctx) // purges the "countries" cache entries
+func (c *Connector) Begin(ctx context.Context) (pgx.Tx, error) {
+	beginner, ok := c.db.(txBeginner)
+	if !ok {
+		return nil, fmt.Errorf("starting transaction: connection can't start a transaction")
+	}
+
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	if _, ok := tx.(*cacheTx); ok {
+		return tx, nil
+	}
+	return &cacheTx{Tx: tx, cache: c.reader.cache}, nil
+}
@@ -0,0 +1,145 @@
+package sqlreader
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReplicaSelection determines how a cluster connector picks a read replica.
+type ReplicaSelection int
+
+const (
+	// RoundRobin sends read queries to each replica in turn.
+	RoundRobin ReplicaSelection = iota
+
+	// LeastConnections sends read queries to the replica with the fewest
+	// acquired connections.
+	LeastConnections
+)
+
+// WithReplicaSelection sets how connectors created by ConnectCluster choose
+// between replicas. The default is RoundRobin.
+func WithReplicaSelection(selection ReplicaSelection) Option {
+	return func(r *SQLReader) {
+		r.replicaSelection = selection
+	}
+}
+
+// primaryKey is the context key used to pin reads to the primary.
+type primaryKey struct{}
+
+// WithPrimary returns a context that routes all queries of a cluster connector
+// to the primary, including read-only ones.
+//
+// This is useful to read your own writes without being affected by replication lag.
+//
+// Example:
+//
+//	if err := conn.Exec(ctx, "create_user", "john", "John Doe"); err != nil {
+//	    return err
+//	}
+//	ctx = sqlreader.WithPrimary(ctx)
+//	err := conn.QueryRow(ctx, "get_user_by_username", scan, "john")
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// pinnedToPrimary reports whether reads in ctx must go to the primary.
+func pinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// ConnectCluster creates a new connector that spreads read-only queries over
+// a set of replica pools.
+//
+// Named queries annotated with "-- readonly" are sent to a replica chosen
+// according to WithReplicaSelection, unless they have a locking clause such as
+// FOR UPDATE. All other queries, migrations and queries in a context created
+// with WithPrimary go to the primary. Unannotated SELECT statements stay on the
+// primary on purpose, as they can call writing functions such as nextval.
+// Without replicas every query goes to the primary.
+//
+// Example:
+//
+//	// -- name: list_users
+//	// -- readonly
+//	// SELECT id, username FROM users
+//	conn := reader.ConnectCluster(primary, replica1, replica2)
+//
+//	// Runs on a replica
+//	err := conn.QueryRows(ctx, "list_users", scan)
+func (r *SQLReader) ConnectCluster(primary *pgxpool.Pool, replicas ...*pgxpool.Pool) *Connector {
+	loader := r.newLoader(primary)
+	if len(replicas) > 0 {
+		loader.cluster = &cluster{
+			primary:   primary,
+			replicas:  replicas,
+			selection: r.replicaSelection,
+		}
+	}
+
+	return &Connector{
+		db:     primary,
+		reader: r,
+		loader: loader,
+	}
+}
+
+// cluster routes queries between a primary pool and its read replicas.
+type cluster struct {
+	primary   *pgxpool.Pool
+	replicas  []*pgxpool.Pool
+	selection ReplicaSelection
+	next      atomic.Uint64
+}
+
+// route returns the pool that should run the named query.
+func (c *cluster) route(ctx context.Context, sql string, meta queryMeta) DB {
+	if pinnedToPrimary(ctx) || !isReadOnly(sql, meta) {
+		return c.primary
+	}
+	return c.replica()
+}
+
+// replica picks a replica pool according to the selection strategy.
+func (c *cluster) replica() *pgxpool.Pool {
+	if c.selection == LeastConnections {
+		best := c.replicas[0]
+		bestConns := best.Stat().AcquiredConns()
+		for _, pool := range c.replicas[1:] {
+			if conns := pool.Stat().AcquiredConns(); conns < bestConns {
+				best, bestConns = pool, conns
+			}
+		}
+		return best
+	}
+
+	n := c.next.Add(1) - 1
+	return c.replicas[n%uint64(len(c.replicas))]
+}
+
+// isReadOnly reports whether a query may run on a replica. Queries must be
+// annotated with "-- readonly", since a SELECT can call volatile or writing
+// functions such as nextval. Queries with a locking clause always run on the
+// primary, even when annotated.
+func isReadOnly(sql string, meta queryMeta) bool {
+	if _, ok := meta.annotation("readonly"); !ok {
+		return false
+	}
+
+	fields := strings.Fields(strings.ToUpper(sql))
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "FOR" {
+			switch fields[i+1] {
+			case "UPDATE", "SHARE", "NO", "KEY":
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+package sqlreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Test detection of queries that may run on a replica
+func TestIsReadOnly(t *testing.T) {
+	readonly := queryMeta{annotations: map[string]string{"readonly": ""}}
+
+	tests := []struct {
+		name     string
+		sql      string
+		meta     queryMeta
+		expected bool
+	}{
+		{name: "annotated select", sql: "SELECT id FROM users", meta: readonly, expected: true},
+		{name: "lowercase select", sql: "select id\nfrom users", meta: readonly, expected: true},
+		{name: "unannotated select", sql: "SELECT id FROM users", expected: false},
+		{name: "select nextval", sql: "SELECT nextval('users_id_seq')", expected: false},
+		{name: "select writing function", sql: "SELECT archive_user($1)", expected: false},
+		{name: "select for update", sql: "SELECT id FROM users WHERE id = $1 FOR UPDATE", meta: readonly, expected: false},
+		{name: "select for share", sql: "SELECT id FROM users FOR SHARE", meta: readonly, expected: false},
+		{name: "select for key share", sql: "SELECT id FROM users FOR KEY SHARE", meta: readonly, expected: false},
+		{name: "insert", sql: "INSERT INTO users (name) VALUES ($1)", expected: false},
+		{name: "annotated cte", sql: "WITH u AS (SELECT id FROM users) SELECT * FROM u", meta: readonly, expected: true},
+		{name: "unannotated cte", sql: "WITH u AS (SELECT id FROM users) SELECT * FROM u", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := isReadOnly(tc.sql, tc.meta); result != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+// Test routing between primary and replicas
+func TestCluster_Route(t *testing.T) {
+	newPool := func() *pgxpool.Pool {
+		pool, err := pgxpool.New(context.Background(), "postgres://postgres@localhost:5432/test")
+		if err != nil {
+			t.Fatalf("Failed to create pool: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		return pool
+	}
+
+	primary, replica1, replica2 := newPool(), newPool(), newPool()
+	c := &cluster{primary: primary, replicas: []*pgxpool.Pool{replica1, replica2}}
+	ctx := context.Background()
+	readonly := queryMeta{annotations: map[string]string{"readonly": ""}}
+
+	if conn := c.route(ctx, "SELECT 1", readonly); conn != replica1 {
+		t.Error("Expected first read to go to the first replica")
+	}
+	if conn := c.route(ctx, "SELECT 1", readonly); conn != replica2 {
+		t.Error("Expected second read to go to the second replica")
+	}
+	if conn := c.route(ctx, "DELETE FROM users", queryMeta{}); conn != primary {
+		t.Error("Expected write to go to the primary")
+	}
+	if conn := c.route(ctx, "SELECT 1", queryMeta{}); conn != primary {
+		t.Error("Expected unannotated read to go to the primary")
+	}
+	if conn := c.route(WithPrimary(ctx), "SELECT 1", readonly); conn != primary {
+		t.Error("Expected pinned read to go to the primary")
+	}
+
+	c.selection = LeastConnections
+	if conn := c.route(ctx, "SELECT 1", readonly); conn != replica1 {
+		t.Error("Expected least-connections read to go to the first idle replica")
+	}
+}
@@ -0,0 +1,99 @@
+package sqlreader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorMapping translates PostgreSQL errors into application errors.
+//
+// Mappings are matched in order of specificity: constraint name first, then the
+// full five-character SQLSTATE code, then the two-character SQLSTATE class.
+// The zero value is not usable; create mappings with NewErrorMapping.
+//
+// Example:
+//
+//	var (
+//	    ErrUsernameTaken     = errors.New("username taken")
+//	    ErrNotFoundReference = errors.New("referenced row not found")
+//	)
+//
+//	mapping := sqlreader.NewErrorMapping().
+//	    Constraint("users_username_key", ErrUsernameTaken).
+//	    Code("23503", ErrNotFoundReference)
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations", sqlreader.WithErrorMapping(mapping))
+type ErrorMapping struct {
+	constraints map[string]error
+	codes       map[string]error
+}
+
+// NewErrorMapping creates an empty error mapping.
+func NewErrorMapping() *ErrorMapping {
+	return &ErrorMapping{
+		constraints: make(map[string]error),
+		codes:       make(map[string]error),
+	}
+}
+
+// Constraint maps violations of the named constraint to target.
+// It returns the mapping to allow chaining.
+func (m *ErrorMapping) Constraint(name string, target error) *ErrorMapping {
+	m.constraints[name] = target
+	return m
+}
+
+// Code maps a SQLSTATE code to target. The code is either a full
+// five-character code such as "23505" or a two-character class such as "23".
+// It returns the mapping to allow chaining.
+func (m *ErrorMapping) Code(code string, target error) *ErrorMapping {
+	m.codes[code] = target
+	return m
+}
+
+// lookup returns the application error registered for pgErr, or nil if none matches.
+func (m *ErrorMapping) lookup(pgErr *pgconn.PgError) error {
+	if target, ok := m.constraints[pgErr.ConstraintName]; ok && pgErr.ConstraintName != "" {
+		return target
+	}
+	if target, ok := m.codes[pgErr.Code]; ok {
+		return target
+	}
+	if len(pgErr.Code) >= 2 {
+		if target, ok := m.codes[pgErr.Code[:2]]; ok {
+			return target
+		}
+	}
+	return nil
+}
+
+// apply wraps err with the mapped application error, if any. Both the mapped
+// error and the original remain in the wrap chain, so errors.Is works on the
+// application error and errors.As still finds the *QueryError and *pgconn.PgError.
+func (m *ErrorMapping) apply(err error) error {
+	if m == nil {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	target := m.lookup(pgErr)
+	if target == nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", target, err)
+}
+
+// WithErrorMapping makes all connectors translate PostgreSQL errors returned by
+// Exec, QueryRow and QueryRows using the given mapping.
+func WithErrorMapping(m *ErrorMapping) Option {
+	return func(r *SQLReader) {
+		r.errorMapping = m
+	}
+}
@@ -0,0 +1,59 @@
+package sqlreader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Test error mapping precedence and wrap chain
+func TestErrorMapping_Apply(t *testing.T) {
+	errUsernameTaken := errors.New("username taken")
+	errDuplicate := errors.New("duplicate")
+	errIntegrity := errors.New("integrity violation")
+
+	mapping := NewErrorMapping().
+		Constraint("users_username_key", errUsernameTaken).
+		Code("23505", errDuplicate).
+		Code("23", errIntegrity)
+
+	tests := []struct {
+		name     string
+		pgErr    *pgconn.PgError
+		expected error
+	}{
+		{name: "constraint", pgErr: &pgconn.PgError{Code: "23505", ConstraintName: "users_username_key"}, expected: errUsernameTaken},
+		{name: "code", pgErr: &pgconn.PgError{Code: "23505", ConstraintName: "posts_slug_key"}, expected: errDuplicate},
+		{name: "class", pgErr: &pgconn.PgError{Code: "23503"}, expected: errIntegrity},
+		{name: "unmapped", pgErr: &pgconn.PgError{Code: "42P01"}, expected: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			original := fmt.Errorf("executing create_user: %w", tc.pgErr)
+			err := mapping.apply(original)
+
+			if tc.expected == nil {
+				if err != original {
+					t.Errorf("Expected unmapped error to be returned as is, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v in the error chain, got %v", tc.expected, err)
+			}
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr != tc.pgErr {
+				t.Error("Expected original *pgconn.PgError in the error chain")
+			}
+		})
+	}
+
+	var nilMapping *ErrorMapping
+	if err := nilMapping.apply(errDuplicate); err != errDuplicate {
+		t.Errorf("Expected nil mapping to return the error unchanged, got %v", err)
+	}
+}
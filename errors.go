@@ -0,0 +1,131 @@
+package sqlreader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryPhase identifies the step of a named query execution that failed.
+type QueryPhase string
+
+const (
+	// PhaseExecute means the database rejected or failed to run the query.
+	PhaseExecute QueryPhase = "execute"
+
+	// PhaseScan means the caller's scanner function returned an error.
+	PhaseScan QueryPhase = "scan"
+
+	// PhaseRows means an error occurred while iterating over the result rows.
+	PhaseRows QueryPhase = "rows"
+)
+
+// QueryError describes a failed named query.
+//
+// All errors returned by Exec, QueryRow and QueryRows wrap a *QueryError,
+// which can be retrieved with errors.As. When the failure comes from
+// PostgreSQL, the fields of the underlying *pgconn.PgError are copied over and
+// the error position is mapped back to a line and column in the .sql file.
+//
+// Example:
+//
+//	var qerr *sqlreader.QueryError
+//	if errors.As(err, &qerr) && qerr.Code == "23505" {
+//	    log.Printf("%s violates %s (%s:%d:%d)", qerr.Name, qerr.ConstraintName, qerr.File, qerr.Line, qerr.Column)
+//	}
+type QueryError struct {
+	Name     string     // The name of the query
+	Phase    QueryPhase // The step that failed
+	SQL      string     // The SQL text sent to the database
+	ArgCount int        // The number of arguments passed to the query
+
+	Code           string // SQLSTATE error code, empty if the error didn't come from PostgreSQL
+	ConstraintName string // Name of the violated constraint, if any
+	TableName      string // Name of the table involved, if any
+	Position       int32  // 1-based character position of the error in SQL, 0 if unknown
+
+	File   string // The .sql file declaring the query, if known
+	Line   int    // 1-based line of Position in File, 0 if unknown or SQL isn't the file's text
+	Column int    // 1-based column of Position in File, 0 if unknown or SQL isn't the file's text
+
+	Err error // The underlying error
+
+	multiRow bool // Whether the error comes from QueryRows, which words its messages differently
+}
+
+// Error returns a description of the failed query in the same form as the
+// original wrapped error messages, e.g. "executing create_user: ...".
+// QueryRows failures keep their "executing %s query" and "scanning %s results"
+// forms. Errors while iterating rows, which used to be returned unwrapped,
+// read "reading %s rows: ...".
+func (e *QueryError) Error() string {
+	switch e.Phase {
+	case PhaseScan:
+		if e.multiRow {
+			return fmt.Sprintf("scanning %s results: %v", e.Name, e.Err)
+		}
+		return fmt.Sprintf("scanning %s result: %v", e.Name, e.Err)
+	case PhaseRows:
+		return fmt.Sprintf("reading %s rows: %v", e.Name, e.Err)
+	default:
+		if e.multiRow {
+			return fmt.Sprintf("executing %s query: %v", e.Name, e.Err)
+		}
+		return fmt.Sprintf("executing %s: %v", e.Name, e.Err)
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// newQueryError builds a QueryError for the named query, copying the details
+// of a wrapped *pgconn.PgError when there is one.
+func newQueryError(name string, phase QueryPhase, sql string, meta queryMeta, args []interface{}, err error) *QueryError {
+	qerr := &QueryError{
+		Name:     name,
+		Phase:    phase,
+		SQL:      sql,
+		ArgCount: len(args),
+		File:     meta.file,
+		Err:      err,
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		qerr.Code = pgErr.Code
+		qerr.ConstraintName = pgErr.ConstraintName
+		qerr.TableName = pgErr.TableName
+		qerr.Position = pgErr.Position
+		qerr.Line, qerr.Column = meta.locate(sql, pgErr.Position)
+	}
+
+	return qerr
+}
+
+// locate maps a 1-based character position in the query's SQL text to a line
+// and column in the source file. It returns zeros if the position is unknown.
+func (m queryMeta) locate(sql string, position int32) (line, column int) {
+	if position <= 0 || m.line == 0 {
+		return 0, 0
+	}
+
+	line, column = m.line, m.column
+	remaining := int(position) - 1
+	for _, r := range sql {
+		if remaining == 0 {
+			break
+		}
+		remaining--
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return line, column
+}
@@ -0,0 +1,134 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test mapping of error positions back to the source file
+func TestQueryMeta_Locate(t *testing.T) {
+	qs := &queryStore{queries: make(map[string]string)}
+	err := qs.parseQueries("sql/users.sql", `-- name: list_users
+SELECT id FROM users
+
+-- name: create_user
+-- redact: 1
+INSERT INTO users (username, name)
+VALUES ($1, $2)`)
+	if err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+
+	meta := qs.metadata("create_user")
+	if meta.file != "sql/users.sql" || meta.line != 6 || meta.column != 1 {
+		t.Fatalf("Unexpected query location %s:%d:%d", meta.file, meta.line, meta.column)
+	}
+
+	tests := []struct {
+		position int32
+		line     int
+		column   int
+	}{
+		{position: 0, line: 0, column: 0},
+		{position: 1, line: 6, column: 1},
+		{position: 13, line: 6, column: 13},
+		{position: 36, line: 7, column: 1},
+		{position: 44, line: 7, column: 9},
+	}
+
+	sql := qs.get("create_user")
+	for _, tc := range tests {
+		line, column := meta.locate(sql, tc.position)
+		if line != tc.line || column != tc.column {
+			t.Errorf("Position %d: expected %d:%d, got %d:%d", tc.position, tc.line, tc.column, line, column)
+		}
+	}
+}
+
+// Test that query failures are returned as *QueryError
+func TestQueryLoader_QueryError(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	qs := &queryStore{queries: make(map[string]string)}
+	if err := qs.parseQueries("sql/users.sql", "-- name: create_user\nINSERT INTO users (username)\nVALUES ($1)"); err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+	loader := &queryLoader{db: mock, querier: qs}
+
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("john").
+		WillReturnError(&pgconn.PgError{
+			Code:           "23505",
+			ConstraintName: "users_username_key",
+			TableName:      "users",
+			Position:       31,
+		})
+
+	err = loader.exec(context.Background(), "create_user", "john")
+
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Expected *QueryError, got %T: %v", err, err)
+	}
+	if qerr.Name != "create_user" || qerr.Phase != PhaseExecute || qerr.ArgCount != 1 {
+		t.Errorf("Unexpected query details: %+v", qerr)
+	}
+	if qerr.Code != "23505" || qerr.ConstraintName != "users_username_key" || qerr.TableName != "users" {
+		t.Errorf("Unexpected PgError details: %+v", qerr)
+	}
+	if qerr.File != "sql/users.sql" || qerr.Line != 3 || qerr.Column != 2 {
+		t.Errorf("Expected position sql/users.sql:3:2, got %s:%d:%d", qerr.File, qerr.Line, qerr.Column)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		t.Error("Expected *pgconn.PgError in the error chain")
+	}
+}
+
+// Test that QueryRows keeps its error messages and wrapped SQL isn't located in the file
+func TestQueryLoader_QueryErrorMessages(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	qs := &queryStore{queries: make(map[string]string)}
+	if err := qs.parseQueries("sql/posts.sql", "-- name: list_posts\n-- paginate: id DESC\nSELECT id FROM posts"); err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+	loader := &queryLoader{db: mock, querier: qs, cursorKey: []byte("secret")}
+
+	mock.ExpectQuery("SELECT id FROM posts").WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery("SELECT id FROM posts").WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery("ORDER BY page.id DESC").WithArgs(11).WillReturnError(&pgconn.PgError{Code: "42703", Position: 3})
+
+	err = loader.queryRows(context.Background(), "list_posts", func(pgx.Rows) error { return nil })
+	if err == nil || err.Error() != "executing list_posts query: connection reset" {
+		t.Errorf("Unexpected execute error: %v", err)
+	}
+
+	err = loader.queryRows(context.Background(), "list_posts", func(pgx.Rows) error { return errors.New("bad row") })
+	if err == nil || err.Error() != "scanning list_posts results: bad row" {
+		t.Errorf("Unexpected scan error: %v", err)
+	}
+
+	_, err = loader.paginate(context.Background(), "list_posts", "", 10)
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Expected *QueryError, got %T: %v", err, err)
+	}
+	if qerr.Position != 3 || qerr.Line != 0 || qerr.Column != 0 {
+		t.Errorf("Expected no file position for wrapped SQL, got %d:%d (%v)", qerr.Line, qerr.Column, qerr)
+	}
+}
@@ -54,49 +54,8 @@ func main() {
 	conn := reader.ConnectPool(pool)
 
 	// Apply only the first migration (users table)
-	// We simulate this by programmatically applying just the first migration
-	tx, err := pool.Begin(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to begin transaction: %v", err)
-	}
-
-	// Create a connector with the transaction
-	txConn := reader.ConnectTx(tx)
-
-	// Initialize migrations table
-	if err := txConn.InitiateMigration(context.Background()); err != nil {
-		tx.Rollback(context.Background())
-		log.Fatalf("Failed to initialize migrations table: %v", err)
-	}
-
-	// Manually execute just the first migration
-	if _, err := tx.Exec(context.Background(), `
-		CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			username VARCHAR(100) NOT NULL UNIQUE,
-			name VARCHAR(200) NOT NULL,
-			preferences JSONB DEFAULT '{}'::jsonb,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_users_preferences ON users USING gin (preferences);
-	`); err != nil {
-		tx.Rollback(context.Background())
-		log.Fatalf("Failed to create users table: %v", err)
-	}
-
-	// Record the migration
-	if _, err := tx.Exec(context.Background(), `
-		INSERT INTO schema_migrations (version, name, applied_at)
-		VALUES (1, 'create_users', $1)
-	`, time.Now().UTC()); err != nil {
-		tx.Rollback(context.Background())
-		log.Fatalf("Failed to record migration: %v", err)
-	}
-
-	// Commit the transaction
-	if err := tx.Commit(context.Background()); err != nil {
-		log.Fatalf("Failed to commit transaction: %v", err)
+	if err := conn.MigrateTo(context.Background(), 1); err != nil {
+		log.Fatalf("Failed to apply initial migration: %v", err)
 	}
 
 	fmt.Println("✅ Initial schema created successfully (users table)")
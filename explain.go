@@ -0,0 +1,153 @@
+package sqlreader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExplainOptions selects the EXPLAIN options used by Explain.
+type ExplainOptions struct {
+	// Analyze executes the query to report actual timings and row counts.
+	// Queries that aren't read-only run in a transaction that is rolled back.
+	Analyze bool
+
+	// Buffers reports buffer usage. It is only meaningful with Analyze.
+	Buffers bool
+}
+
+// Plan is a query plan returned by Explain.
+type Plan struct {
+	Root          PlanNode `json:"Plan"`
+	PlanningTime  float64  `json:"Planning Time"`  // Milliseconds, only with Analyze
+	ExecutionTime float64  `json:"Execution Time"` // Milliseconds, only with Analyze
+}
+
+// PlanNode is a single node of a query plan.
+// Actual and buffer fields are only set when the plan was produced with Analyze and Buffers.
+type PlanNode struct {
+	NodeType     string `json:"Node Type"`
+	RelationName string `json:"Relation Name"`
+	Alias        string `json:"Alias"`
+	IndexName    string `json:"Index Name"`
+	JoinType     string `json:"Join Type"`
+
+	StartupCost float64 `json:"Startup Cost"`
+	TotalCost   float64 `json:"Total Cost"`
+	PlanRows    float64 `json:"Plan Rows"`
+	PlanWidth   int     `json:"Plan Width"`
+
+	ActualStartupTime float64 `json:"Actual Startup Time"`
+	ActualTotalTime   float64 `json:"Actual Total Time"`
+	ActualRows        float64 `json:"Actual Rows"`
+	ActualLoops       float64 `json:"Actual Loops"`
+
+	SharedHitBlocks  int64 `json:"Shared Hit Blocks"`
+	SharedReadBlocks int64 `json:"Shared Read Blocks"`
+
+	Plans []PlanNode `json:"Plans"`
+}
+
+// Walk calls fn for every node of the plan in depth-first order,
+// stopping early if fn returns false.
+func (p *Plan) Walk(fn func(node *PlanNode) bool) {
+	p.Root.walk(fn)
+}
+
+// walk visits n and its children, reporting false if the walk was stopped.
+func (n *PlanNode) walk(fn func(node *PlanNode) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for i := range n.Plans {
+		if !n.Plans[i].walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether any node of the plan has the given node type,
+// for example "Seq Scan" or "Index Only Scan".
+func (p *Plan) Contains(nodeType string) bool {
+	found := false
+	p.Walk(func(node *PlanNode) bool {
+		found = node.NodeType == nodeType
+		return !found
+	})
+	return found
+}
+
+// Explain runs EXPLAIN (FORMAT JSON) on a named query and returns its plan.
+//
+// With ExplainOptions.Analyze the query is executed. Queries that aren't
+// read-only, as determined for ConnectCluster, run in a transaction (or a
+// savepoint inside an existing transaction) that is always rolled back.
+//
+// Example:
+//
+//	plan, err := conn.Explain(ctx, "get_user_by_username", sqlreader.ExplainOptions{}, "john")
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	if plan.Contains("Seq Scan") {
+//	    t.Error("get_user_by_username does a sequential scan")
+//	}
+func (c *Connector) Explain(ctx context.Context, name string, opts ExplainOptions, args ...interface{}) (*Plan, error) {
+	return c.loader.explain(ctx, name, opts, args...)
+}
+
+// explainSQL prefixes a query with EXPLAIN and the selected options.
+func explainSQL(query string, opts ExplainOptions) string {
+	options := []string{"FORMAT JSON"}
+	if opts.Analyze {
+		options = append(options, "ANALYZE")
+	}
+	if opts.Buffers {
+		options = append(options, "BUFFERS")
+	}
+	return "EXPLAIN (" + strings.Join(options, ", ") + ")\n" + query
+}
+
+// explain implements Connector.Explain.
+func (l *queryLoader) explain(ctx context.Context, name string, opts ExplainOptions, args ...interface{}) (*Plan, error) {
+	query := l.querier.get(name)
+	sql := explainSQL(query, opts)
+	db := l.conn(ctx, name, query)
+
+	var output string
+	run := func(db DB) error {
+		return db.QueryRow(ctx, sql, args...).Scan(&output)
+	}
+
+	var err error
+	if opts.Analyze && !isReadOnly(query, l.querier.metadata(name)) {
+		beginner, ok := db.(txBeginner)
+		if !ok {
+			return nil, fmt.Errorf("explaining %s: connection can't start a transaction", name)
+		}
+		tx, beginErr := beginner.Begin(ctx)
+		if beginErr != nil {
+			return nil, fmt.Errorf("explaining %s: starting transaction: %w", name, beginErr)
+		}
+		// The analyzed statement has side effects that must never be kept
+		err = l.withSettings(ctx, tx, run)
+		tx.Rollback(ctx)
+	} else {
+		err = l.withSettings(ctx, db, run)
+	}
+	if err != nil {
+		return nil, l.wrapError(name, PhaseExecute, sql, args, err)
+	}
+
+	var plans []Plan
+	if err := json.Unmarshal([]byte(output), &plans); err != nil {
+		return nil, fmt.Errorf("explaining %s: decoding plan: %w", name, err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("explaining %s: empty plan", name)
+	}
+
+	return &plans[0], nil
+}
@@ -0,0 +1,62 @@
+package sqlreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+const testPlanJSON = `[{"Plan": {"Node Type": "Nested Loop", "Total Cost": 16.5, "Plan Rows": 1,
+	"Plans": [
+		{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_username_key", "Total Cost": 8.2},
+		{"Node Type": "Seq Scan", "Relation Name": "posts", "Total Cost": 8.3}
+	]}, "Execution Time": 0.25}]`
+
+// Test explaining named queries and inspecting the plan tree
+func TestConnector_Explain(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{queries: &queryStore{
+		queries: map[string]string{
+			"get_user_posts": "SELECT * FROM users JOIN posts ON posts.user_id = users.id WHERE username = $1",
+			"delete_user":    "DELETE FROM users WHERE username = $1",
+		},
+	}}
+	conn := &Connector{db: mock, reader: reader, loader: reader.newLoader(mock)}
+
+	mock.ExpectQuery("EXPLAIN \\(FORMAT JSON\\)\nSELECT \\* FROM users").
+		WithArgs("john").
+		WillReturnRows(pgxmock.NewRows([]string{"QUERY PLAN"}).AddRow(testPlanJSON))
+	mock.ExpectBegin()
+	mock.ExpectQuery("EXPLAIN \\(FORMAT JSON, ANALYZE, BUFFERS\\)\nDELETE FROM users").
+		WithArgs("john").
+		WillReturnRows(pgxmock.NewRows([]string{"QUERY PLAN"}).AddRow(testPlanJSON))
+	mock.ExpectRollback()
+
+	plan, err := conn.Explain(context.Background(), "get_user_posts", ExplainOptions{}, "john")
+	if err != nil {
+		t.Fatalf("Explain returned an error: %v", err)
+	}
+	if plan.Root.NodeType != "Nested Loop" || len(plan.Root.Plans) != 2 || plan.ExecutionTime != 0.25 {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+	if plan.Root.Plans[0].IndexName != "users_username_key" {
+		t.Errorf("Expected index scan on users_username_key, got %+v", plan.Root.Plans[0])
+	}
+	if !plan.Contains("Seq Scan") || plan.Contains("Hash Join") {
+		t.Error("Contains reported unexpected node types")
+	}
+
+	if _, err := conn.Explain(context.Background(), "delete_user", ExplainOptions{Analyze: true, Buffers: true}, "john"); err != nil {
+		t.Errorf("Explain with analyze returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
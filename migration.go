@@ -2,9 +2,15 @@ package sqlreader
 
 import (
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,25 +21,53 @@ import (
 // It's responsible for loading migration files, tracking applied migrations,
 // and applying or rolling back migrations as needed.
 type migrationManager struct {
-	db            dbConn
+	db            DB
 	queries       embed.FS
 	migrationsDir string
+	funcs         []migration // Go migrations registered with SQLReader.RegisterMigration
+
+	table      string // Sanitized, possibly schema-qualified migrations table, see tableName
+	schema     string // Schema of the migrations table, created if missing
+	appVersion string // Application version recorded with each migration
+
+	outOfOrder OutOfOrderPolicy
+	logger     *slog.Logger
+
+	// pool runs migrations that can't run in a transaction, see outsideTx.
+	// It is nil when the caller owns the transaction.
+	pool         migrationPool
+	perMigration bool // Commit after each migration, see TransactionPerMigration
+	splitTx      bool // Let no-transaction migrations commit a single transaction, see WithNoTransactionCommits
+
+	// searchPath is the tenant search_path set in every migration transaction.
+	// Migrations outside a transaction aren't supported with it.
+	searchPath string
+
+	version   int64 // Version the database is at, including uncommitted migrations
+	committed int64 // Version the database is at as of the last commit
 }
 
 // migration represents a single database migration.
 // Each migration has a version number, name, up and down SQL statements,
 // and a timestamp indicating when it was applied.
 type migration struct {
-	Version   int       // The migration version number (used for sorting)
+	Version   int64     // The migration version number (used for sorting)
 	Name      string    // A descriptive name for the migration
 	UpSQL     string    // SQL to apply the migration
 	DownSQL   string    // SQL to revert the migration
+	Checksum  string    // Hex-encoded SHA-256 of UpSQL, empty for records stamped before checksums
 	AppliedAt time.Time // When the migration was applied
+
+	NoTransaction bool // Declared with the "-- sqlreader:no-transaction" directive
+	Dirty         bool // A migration run outside a transaction failed partway
+
+	UpFunc   MigrationFunc // Go function applying the migration instead of UpSQL
+	DownFunc MigrationFunc // Go function reverting the migration instead of DownSQL
 }
 
 // newMigrationManager creates a new migration manager with the given
 // database connection, embedded filesystem, and migrations directory.
-func newMigrationManager(db dbConn, queries embed.FS, migrationsDir string) *migrationManager {
+func newMigrationManager(db DB, queries embed.FS, migrationsDir string) *migrationManager {
 	return &migrationManager{
 		db:            db,
 		queries:       queries,
@@ -41,16 +75,51 @@ func newMigrationManager(db dbConn, queries embed.FS, migrationsDir string) *mig
 	}
 }
 
+// tableName returns the migrations table for use in SQL, schema_migrations
+// unless WithMigrationTable or WithMigrationSchema is set.
+func (m *migrationManager) tableName() string {
+	if m.table == "" {
+		return "schema_migrations"
+	}
+	return m.table
+}
+
 // Initialize creates the migrations table if it doesn't exist.
 // This table is used to track which migrations have been applied.
+// Tables created by older releases are upgraded with the columns added since,
+// and INTEGER versions are widened to BIGINT for timestamp versions.
 func (m *migrationManager) Initialize(ctx context.Context) error {
+	table := m.tableName()
+
 	createTableSQL := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version     INTEGER PRIMARY KEY,
-			name        TEXT NOT NULL,
-			applied_at  TIMESTAMP WITH TIME ZONE NOT NULL
+		CREATE TABLE IF NOT EXISTS ` + table + ` (
+			version       BIGINT PRIMARY KEY,
+			name          TEXT NOT NULL,
+			applied_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+			checksum      TEXT,
+			dirty         BOOLEAN NOT NULL DEFAULT false,
+			execution_ms  BIGINT,
+			applied_by    TEXT,
+			app_version   TEXT
 		);
+		ALTER TABLE ` + table + `
+			ADD COLUMN IF NOT EXISTS checksum TEXT,
+			ADD COLUMN IF NOT EXISTS dirty BOOLEAN NOT NULL DEFAULT false,
+			ADD COLUMN IF NOT EXISTS execution_ms BIGINT,
+			ADD COLUMN IF NOT EXISTS applied_by TEXT,
+			ADD COLUMN IF NOT EXISTS app_version TEXT;
+		DO $$
+		BEGIN
+			IF (SELECT atttypid FROM pg_attribute
+				WHERE attrelid = '` + strings.ReplaceAll(table, "'", "''") + `'::regclass AND attname = 'version') = 'integer'::regtype THEN
+				ALTER TABLE ` + table + ` ALTER COLUMN version TYPE BIGINT;
+			END IF;
+		END $$;
 	`
+	if m.schema != "" {
+		createTableSQL = "CREATE SCHEMA IF NOT EXISTS " + pgx.Identifier{m.schema}.Sanitize() + ";" + createTableSQL
+	}
+
 	_, err := m.db.Exec(ctx, createTableSQL)
 	if err != nil {
 		return fmt.Errorf("creating migrations table: %w", err)
@@ -60,49 +129,56 @@ func (m *migrationManager) Initialize(ctx context.Context) error {
 
 // LoadMigrations loads all migrations from the embedded filesystem.
 // Migration files are expected to be named in the format "001_create_users.sql"
-// where "001" is the version number and "create_users" is the name.
-// Each file should contain up SQL followed by a "-- Down" separator and down SQL.
+// where "001" is the version number and "create_users" is the name. Versions are
+// 64-bit, so timestamps such as "20261016120000_add_x.sql" work as well.
+// Each file should contain up SQL followed by a "-- Down" line and down SQL.
+// Pairs of "001_create_users.up.sql" and "001_create_users.down.sql" files and
+// files in the goose or sql-migrate format are accepted too.
+// Files containing a "-- sqlreader:no-transaction" line run outside a transaction.
+// Registered Go migrations are merged in by version.
 func (m *migrationManager) LoadMigrations() ([]migration, error) {
 	entries, err := m.queries.ReadDir(m.migrationsDir)
 	if err != nil {
 		return nil, fmt.Errorf("reading migrations directory: %w", err)
 	}
 
-	var migrations []migration
+	// Group files by version so that .up.sql and .down.sql pairs are merged
+	var versions []int64
+	files := make(map[int64][]migrationFile)
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
-			content, err := m.queries.ReadFile(m.migrationsDir + "/" + entry.Name())
+			file, err := parseMigrationFilename(entry.Name())
 			if err != nil {
-				return nil, fmt.Errorf("reading migration file %s: %w", entry.Name(), err)
-			}
-
-			parts := strings.Split(strings.TrimSuffix(entry.Name(), ".sql"), "_")
-			if len(parts) < 2 {
-				return nil, fmt.Errorf("invalid migration filename: %s", entry.Name())
+				return nil, err
 			}
 
-			version := 0
-			_, err = fmt.Sscanf(parts[0], "%d", &version)
+			content, err := m.queries.ReadFile(m.migrationsDir + "/" + entry.Name())
 			if err != nil {
-				return nil, fmt.Errorf("parsing migration version from %s: %w", entry.Name(), err)
+				return nil, fmt.Errorf("reading migration file %s: %w", entry.Name(), err)
 			}
+			file.Content = string(content)
 
-			name := strings.Join(parts[1:], "_")
-			sections := strings.Split(string(content), "-- Down")
-			if len(sections) != 2 {
-				return nil, fmt.Errorf("invalid migration format in %s", entry.Name())
+			if _, ok := files[file.Version]; !ok {
+				versions = append(versions, file.Version)
 			}
+			files[file.Version] = append(files[file.Version], file)
+		}
+	}
 
-			upSQL := strings.TrimSpace(sections[0])
-			downSQL := strings.TrimSpace(sections[1])
+	var migrations []migration
+	for _, version := range versions {
+		mig, err := buildMigration(files[version])
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, mig)
+	}
 
-			migrations = append(migrations, migration{
-				Version: version,
-				Name:    name,
-				UpSQL:   upSQL,
-				DownSQL: downSQL,
-			})
+	for _, fn := range m.funcs {
+		if _, ok := findMigration(migrations, fn.Version); ok {
+			return nil, fmt.Errorf("migration version %d is declared by both a file and a Go migration", fn.Version)
 		}
+		migrations = append(migrations, fn)
 	}
 
 	// Sort migrations by version
@@ -113,12 +189,22 @@ func (m *migrationManager) LoadMigrations() ([]migration, error) {
 	return migrations, nil
 }
 
+// checksum returns the hex-encoded SHA-256 of a migration's up SQL.
+func checksum(upSQL string) string {
+	sum := sha256.Sum256([]byte(upSQL))
+	return hex.EncodeToString(sum[:])
+}
+
 // GetAppliedMigrations returns all migrations that have been applied.
-// It queries the schema_migrations table and returns a map of version to migration.
-func (m *migrationManager) GetAppliedMigrations(ctx context.Context) (map[int]migration, error) {
+// It queries the migrations table and returns a map of version to migration.
+// The checksum and dirty columns are read through to_jsonb so that tables
+// which haven't been upgraded by Initialize yet can still be read.
+func (m *migrationManager) GetAppliedMigrations(ctx context.Context) (map[int64]migration, error) {
 	rows, err := m.db.Query(ctx, `
-		SELECT version, name, applied_at
-		FROM schema_migrations
+		SELECT version, name, applied_at,
+			COALESCE(to_jsonb(m)->>'checksum', ''),
+			COALESCE((to_jsonb(m)->>'dirty')::boolean, false)
+		FROM `+m.tableName()+` AS m
 		ORDER BY version ASC
 	`)
 	if err != nil {
@@ -126,10 +212,10 @@ func (m *migrationManager) GetAppliedMigrations(ctx context.Context) (map[int]mi
 	}
 	defer rows.Close()
 
-	applied := make(map[int]migration)
+	applied := make(map[int64]migration)
 	for rows.Next() {
 		var mig migration
-		err := rows.Scan(&mig.Version, &mig.Name, &mig.AppliedAt)
+		err := rows.Scan(&mig.Version, &mig.Name, &mig.AppliedAt, &mig.Checksum, &mig.Dirty)
 		if err != nil {
 			return nil, fmt.Errorf("scanning migration row: %w", err)
 		}
@@ -139,11 +225,55 @@ func (m *migrationManager) GetAppliedMigrations(ctx context.Context) (map[int]mi
 	return applied, rows.Err()
 }
 
+// ErrMigrationNotFound is returned when a migration version is requested that
+// has no migration file.
+var ErrMigrationNotFound = errors.New("migration not found")
+
+// ErrMigrationModified is returned by Migrate when the up SQL of applied
+// migrations no longer matches the checksum recorded when they were applied.
+//
+// Use errors.As to get the offending versions:
+//
+//	var modified *sqlreader.ErrMigrationModified
+//	if errors.As(err, &modified) {
+//	    log.Printf("migrations %v were edited after being applied", modified.Versions)
+//	}
+type ErrMigrationModified struct {
+	Versions []int64 // The modified migration versions in ascending order
+}
+
+// Error implements the error interface.
+func (e *ErrMigrationModified) Error() string {
+	versions := make([]string, len(e.Versions))
+	for i, v := range e.Versions {
+		versions[i] = strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprintf("applied migrations have been modified: %s", strings.Join(versions, ", "))
+}
+
 // Migrate applies all pending migrations.
 // It first loads all migrations from the filesystem, then checks which ones
 // have already been applied. It then applies any migrations that haven't been
 // applied yet, in order of version number.
 func (m *migrationManager) Migrate(ctx context.Context) error {
+	return m.migrate(ctx, math.MaxInt64)
+}
+
+// MigrateTo applies the pending migrations up to and including the given version.
+func (m *migrationManager) MigrateTo(ctx context.Context, version int64) error {
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return err
+	}
+	if _, ok := findMigration(migrations, version); !ok {
+		return fmt.Errorf("migrating to version %d: %w", version, ErrMigrationNotFound)
+	}
+
+	return m.migrate(ctx, version)
+}
+
+// migrate applies the pending migrations with a version up to target.
+func (m *migrationManager) migrate(ctx context.Context, target int64) error {
 	if err := m.Initialize(ctx); err != nil {
 		return err
 	}
@@ -157,26 +287,120 @@ func (m *migrationManager) Migrate(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-
-	tx, ok := m.db.(pgx.Tx)
-	if !ok {
-		return fmt.Errorf("database connection is not a transaction")
+	if err := checkDirty(applied); err != nil {
+		return err
+	}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		return err
+	}
+	if _, ok := applied[target]; ok && target < latestVersion(applied) {
+		return fmt.Errorf("migrating to version %d: newer migrations are applied up to version %d; use RollbackTo to go back",
+			target, latestVersion(applied))
+	}
+	if err := m.checkOrder(ctx, migrations, applied, target); err != nil {
+		return err
 	}
 
+	var pending []migration
 	for _, migration := range migrations {
+		if migration.Version > target {
+			break
+		}
 		if _, exists := applied[migration.Version]; !exists {
-			// Apply migration
-			if _, err := tx.Exec(ctx, migration.UpSQL); err != nil {
-				return fmt.Errorf("applying migration %d: %w", migration.Version, err)
-			}
+			pending = append(pending, migration)
+		}
+	}
+	if err := m.checkNoTransaction(pending); err != nil {
+		return err
+	}
 
-			// Record migration
-			if _, err := tx.Exec(ctx, `
-				INSERT INTO schema_migrations (version, name, applied_at)
-				VALUES ($1, $2, $3)
-			`, migration.Version, migration.Name, time.Now().UTC()); err != nil {
-				return fmt.Errorf("recording migration %d: %w", migration.Version, err)
-			}
+	m.version = latestVersion(applied)
+	m.committed = m.version
+
+	for _, migration := range pending {
+		if err := m.step(ctx, migration, migration.Version, m.applyOutsideTx, m.applyInTx); err != nil {
+			return &MigrationError{Version: migration.Version, Name: migration.Name, Current: m.committed, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// applyInTx applies a migration and records it.
+func (m *migrationManager) applyInTx(ctx context.Context, tx DB, migration migration) error {
+	// Apply migration
+	start := time.Now()
+	if err := runMigration(ctx, tx, migration.UpFunc, migration.UpSQL); err != nil {
+		return fmt.Errorf("applying migration %d: %w", migration.Version, err)
+	}
+
+	// Record migration
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO `+m.tableName()+` (version, name, applied_at, checksum, execution_ms, applied_by, app_version)
+		VALUES ($1, $2, $3, $4, $5, current_user, $6)
+	`, migration.Version, migration.Name, time.Now().UTC(), migration.Checksum,
+		time.Since(start).Milliseconds(), m.recordedAppVersion()); err != nil {
+		return fmt.Errorf("recording migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// recordedAppVersion returns the application version to record, or nil to
+// record NULL if none is configured.
+func (m *migrationManager) recordedAppVersion() interface{} {
+	if m.appVersion == "" {
+		return nil
+	}
+	return m.appVersion
+}
+
+// verifyChecksums returns an *ErrMigrationModified if the recorded checksum of
+// an applied migration differs from its file. Records without a checksum and
+// migrations whose file is missing are not verified.
+func verifyChecksums(migrations []migration, applied map[int64]migration) error {
+	var modified []int64
+	for _, mig := range migrations {
+		record, ok := applied[mig.Version]
+		if ok && record.Checksum != "" && record.Checksum != mig.Checksum {
+			modified = append(modified, mig.Version)
+		}
+	}
+
+	if len(modified) > 0 {
+		return &ErrMigrationModified{Versions: modified}
+	}
+	return nil
+}
+
+// Repair re-stamps the recorded name and checksum of every applied migration
+// from its migration file. Applied migrations whose file is missing are left as is.
+func (m *migrationManager) Repair(ctx context.Context) error {
+	if err := m.Initialize(ctx); err != nil {
+		return err
+	}
+
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.GetAppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range migrations {
+		record, ok := applied[mig.Version]
+		if !ok || (record.Checksum == mig.Checksum && record.Name == mig.Name) {
+			continue
+		}
+
+		if _, err := m.db.Exec(ctx, `
+			UPDATE `+m.tableName()+`
+			SET name = $1, checksum = $2
+			WHERE version = $3
+		`, mig.Name, mig.Checksum, mig.Version); err != nil {
+			return fmt.Errorf("repairing migration record %d: %w", mig.Version, err)
 		}
 	}
 
@@ -186,53 +410,157 @@ func (m *migrationManager) Migrate(ctx context.Context) error {
 // Rollback reverts the last applied migration.
 // It first determines which migration was applied last, then executes
 // the down SQL for that migration and removes the record from the
-// schema_migrations table.
+// migrations table.
 func (m *migrationManager) Rollback(ctx context.Context) error {
+	return m.RollbackSteps(ctx, 1)
+}
+
+// RollbackTo reverts the applied migrations newer than the given version,
+// newest first.
+func (m *migrationManager) RollbackTo(ctx context.Context, version int64) error {
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return err
+	}
+	if _, ok := findMigration(migrations, version); !ok {
+		return fmt.Errorf("rolling back to version %d: %w", version, ErrMigrationNotFound)
+	}
+
+	return m.rollbackAfter(ctx, version)
+}
+
+// RollbackAll reverts every applied migration, newest first.
+func (m *migrationManager) RollbackAll(ctx context.Context) error {
+	return m.rollbackAfter(ctx, math.MinInt64)
+}
+
+// rollbackAfter reverts the applied migrations newer than version.
+func (m *migrationManager) rollbackAfter(ctx context.Context, version int64) error {
+	applied, err := m.GetAppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkDirty(applied); err != nil {
+		return err
+	}
+
+	var versions []int64
+	for v := range applied {
+		if v > version {
+			versions = append(versions, v)
+		}
+	}
+	return m.revert(ctx, applied, versions)
+}
+
+// RollbackSteps reverts the last n applied migrations, newest first.
+func (m *migrationManager) RollbackSteps(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of rollback steps: %d", n)
+	}
+
 	applied, err := m.GetAppliedMigrations(ctx)
 	if err != nil {
 		return err
 	}
+	if err := checkDirty(applied); err != nil {
+		return err
+	}
+
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sortVersionsDesc(versions)
+	if len(versions) > n {
+		versions = versions[:n]
+	}
+	return m.revert(ctx, applied, versions)
+}
 
-	if len(applied) == 0 {
+// revert executes the down SQL of the given applied migrations, newest first,
+// and removes their records from the migrations table.
+func (m *migrationManager) revert(ctx context.Context, applied map[int64]migration, versions []int64) error {
+	if len(versions) == 0 {
 		return nil
 	}
+	sortVersionsDesc(versions)
 
 	migrations, err := m.LoadMigrations()
 	if err != nil {
 		return err
 	}
 
-	var lastMigration migration
-	var lastVersion int
-	for version := range applied {
-		if version > lastVersion {
-			lastVersion = version
-			for _, m := range migrations {
-				if m.Version == version {
-					lastMigration = m
-					break
-				}
-			}
+	reverted := make([]migration, len(versions))
+	for i, version := range versions {
+		mig, ok := findMigration(migrations, version)
+		if !ok {
+			return fmt.Errorf("rolling back migration %d: %w", version, ErrMigrationNotFound)
 		}
+		reverted[i] = mig
+	}
+	if err := m.checkNoTransaction(reverted); err != nil {
+		return err
 	}
 
-	tx, ok := m.db.(pgx.Tx)
-	if !ok {
-		return fmt.Errorf("database connection is not a transaction")
+	m.version = latestVersion(applied)
+	m.committed = m.version
+
+	for _, migration := range reverted {
+		// The database is at the newest remaining migration once this one is reverted
+		delete(applied, migration.Version)
+		if err := m.step(ctx, migration, latestVersion(applied), m.revertOutsideTx, m.revertInTx); err != nil {
+			return &MigrationError{Version: migration.Version, Name: migration.Name, Current: m.committed, Err: err}
+		}
 	}
 
+	return nil
+}
+
+// revertInTx reverts a migration and removes its record.
+func (m *migrationManager) revertInTx(ctx context.Context, tx DB, migration migration) error {
 	// Apply rollback
-	if _, err := tx.Exec(ctx, lastMigration.DownSQL); err != nil {
-		return fmt.Errorf("rolling back migration %d: %w", lastMigration.Version, err)
+	if migration.UpFunc != nil && migration.DownFunc == nil {
+		return fmt.Errorf("rolling back migration %d: Go migration has no down function", migration.Version)
+	}
+	if err := runMigration(ctx, tx, migration.DownFunc, migration.DownSQL); err != nil {
+		return fmt.Errorf("rolling back migration %d: %w", migration.Version, err)
 	}
 
 	// Remove migration record
 	if _, err := tx.Exec(ctx, `
-		DELETE FROM schema_migrations
+		DELETE FROM `+m.tableName()+`
 		WHERE version = $1
-	`, lastMigration.Version); err != nil {
-		return fmt.Errorf("removing migration record %d: %w", lastMigration.Version, err)
+	`, migration.Version); err != nil {
+		return fmt.Errorf("removing migration record %d: %w", migration.Version, err)
 	}
-
 	return nil
 }
+
+// latestVersion returns the highest applied version, or 0 if none is applied.
+func latestVersion(applied map[int64]migration) int64 {
+	latest := int64(0)
+	for version := range applied {
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// sortVersionsDesc sorts migration versions newest first.
+func sortVersionsDesc(versions []int64) {
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+}
+
+// findMigration returns the migration with the given version.
+func findMigration(migrations []migration, version int64) (migration, bool) {
+	for _, mig := range migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return migration{}, false
+}
@@ -0,0 +1,218 @@
+package sqlreader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// downMarker separates the up and down SQL of a migration file. It only
+// counts on a line of its own, so comments such as "-- Downgrade note" don't
+// split the file.
+const downMarker = "-- Down"
+
+// Annotation prefixes of migration files written for goose and sql-migrate.
+const (
+	gooseAnnotation   = "-- +goose"
+	migrateAnnotation = "-- +migrate"
+)
+
+// migrationFile is a .sql file in the migrations directory.
+//
+// Files named NNN_name.sql hold both directions. Files named NNN_name.up.sql
+// and NNN_name.down.sql, as used by golang-migrate, hold one direction each.
+type migrationFile struct {
+	Filename  string
+	Version   int64
+	Name      string
+	Direction string // "up" or "down" for split files, empty otherwise
+	Content   string
+}
+
+// parseMigrationFilename parses the version, name and direction from a
+// migration filename.
+func parseMigrationFilename(filename string) (migrationFile, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+
+	var direction string
+	for _, d := range []string{"up", "down"} {
+		if strings.HasSuffix(base, "."+d) {
+			base, direction = strings.TrimSuffix(base, "."+d), d
+		}
+	}
+
+	parts := strings.Split(base, "_")
+	if len(parts) < 2 {
+		return migrationFile{}, fmt.Errorf("invalid migration filename: %s", filename)
+	}
+
+	version, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return migrationFile{}, fmt.Errorf("parsing migration version from %s: %w", filename, err)
+	}
+
+	return migrationFile{
+		Filename:  filename,
+		Version:   version,
+		Name:      strings.Join(parts[1:], "_"),
+		Direction: direction,
+	}, nil
+}
+
+// buildMigration builds the migration declared by the files sharing a version:
+// either a single file holding both directions or an up file with an optional
+// down file.
+func buildMigration(files []migrationFile) (migration, error) {
+	first := files[0]
+
+	if len(files) == 1 && first.Direction == "" {
+		upSQL, downSQL, noTx, err := parseMigrationContent(first.Content)
+		if err != nil {
+			return migration{}, fmt.Errorf("invalid migration format in %s: %w", first.Filename, err)
+		}
+		noTx = noTx || hasNoTransactionDirective(first.Content)
+		return newSQLMigration(first.Version, first.Name, upSQL, downSQL, noTx), nil
+	}
+
+	var up, down *migrationFile
+	for i := range files {
+		file := &files[i]
+		switch {
+		case file.Direction == "up" && up == nil:
+			up = file
+		case file.Direction == "down" && down == nil:
+			down = file
+		default:
+			return migration{}, fmt.Errorf("migration version %d is declared by more than one file", first.Version)
+		}
+	}
+
+	if up == nil {
+		return migration{}, fmt.Errorf("migration %s has no matching up file", down.Filename)
+	}
+
+	var downSQL string
+	noTx := hasNoTransactionDirective(up.Content)
+	if down != nil {
+		if down.Name != up.Name {
+			return migration{}, fmt.Errorf("migration files %s and %s have different names", up.Filename, down.Filename)
+		}
+		downSQL = strings.TrimSpace(down.Content)
+		noTx = noTx || hasNoTransactionDirective(down.Content)
+	}
+
+	return newSQLMigration(up.Version, up.Name, strings.TrimSpace(up.Content), downSQL, noTx), nil
+}
+
+// newSQLMigration returns a migration running the given SQL.
+func newSQLMigration(version int64, name, upSQL, downSQL string, noTx bool) migration {
+	return migration{
+		Version:  version,
+		Name:     name,
+		UpSQL:    upSQL,
+		DownSQL:  downSQL,
+		Checksum: checksum(upSQL),
+
+		NoTransaction: noTx,
+	}
+}
+
+// parseMigrationContent splits a migration file holding both directions into
+// its up and down SQL. Files annotated for goose or sql-migrate are parsed in
+// their format; all others must contain exactly one "-- Down" line. The
+// reported no-transaction flag only covers the goose and sql-migrate
+// annotations.
+func parseMigrationContent(content string) (upSQL, downSQL string, noTx bool, err error) {
+	lines := strings.Split(content, "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case isAnnotation(trimmed, gooseAnnotation):
+			return parseAnnotatedMigration(lines, gooseAnnotation)
+		case isAnnotation(trimmed, migrateAnnotation):
+			return parseAnnotatedMigration(lines, migrateAnnotation)
+		}
+	}
+
+	var up, down []string
+	section := &up
+	markers := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == downMarker {
+			markers++
+			section = &down
+			continue
+		}
+		*section = append(*section, line)
+	}
+	if markers != 1 {
+		return "", "", false, fmt.Errorf("expected one %q line, found %d", downMarker, markers)
+	}
+
+	return joinSQL(up), joinSQL(down), false, nil
+}
+
+// parseAnnotatedMigration parses a migration in the goose or sql-migrate
+// format. The up section starts at "-- +goose Up" or "-- +migrate Up" and the
+// optional down section at the matching Down annotation.
+//
+// "-- +goose NO TRANSACTION" and "-- +migrate Up notransaction" mark the
+// migration to run outside a transaction. StatementBegin and StatementEnd
+// annotations are dropped, as statements are split on their own.
+func parseAnnotatedMigration(lines []string, prefix string) (upSQL, downSQL string, noTx bool, err error) {
+	var up, down []string
+	var section *[]string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !isAnnotation(trimmed, prefix) {
+			if section == nil {
+				if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+					return "", "", false, fmt.Errorf("line %d: SQL before the %s Up annotation", i+1, prefix)
+				}
+				continue
+			}
+			*section = append(*section, line)
+			continue
+		}
+
+		args := strings.Fields(strings.ToLower(strings.TrimPrefix(trimmed, prefix)))
+		switch {
+		case len(args) == 0:
+			return "", "", false, fmt.Errorf("line %d: empty %s annotation", i+1, prefix)
+		case args[0] == "up" && section == nil:
+			section = &up
+			for _, option := range args[1:] {
+				if option != "notransaction" || prefix != migrateAnnotation {
+					return "", "", false, fmt.Errorf("line %d: unsupported option %q", i+1, option)
+				}
+				noTx = true
+			}
+		case args[0] == "down" && section == &up && len(args) == 1:
+			section = &down
+		case args[0] == "up" || args[0] == "down":
+			return "", "", false, fmt.Errorf("line %d: unexpected %s", i+1, trimmed)
+		case args[0] == "statementbegin" || args[0] == "statementend":
+		case prefix == gooseAnnotation && strings.Join(args, " ") == "no transaction":
+			noTx = true
+		default:
+			return "", "", false, fmt.Errorf("line %d: unsupported annotation %s", i+1, trimmed)
+		}
+	}
+
+	if section == nil {
+		return "", "", false, fmt.Errorf("missing %s Up annotation", prefix)
+	}
+
+	return joinSQL(up), joinSQL(down), noTx, nil
+}
+
+// isAnnotation reports whether a trimmed line is an annotation with the given
+// prefix, such as "-- +goose Up".
+func isAnnotation(line, prefix string) bool {
+	return line == prefix || strings.HasPrefix(line, prefix+" ")
+}
+
+// joinSQL joins the lines of a section and trims surrounding whitespace.
+func joinSQL(lines []string) string {
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
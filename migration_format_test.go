@@ -0,0 +1,191 @@
+package sqlreader
+
+import (
+	"embed"
+	"strings"
+	"testing"
+)
+
+//go:embed testdata/formats/*.sql
+var testFormatMigrationsFS embed.FS
+
+// Test loading split up/down files, goose and sql-migrate migrations
+func TestMigrationManager_LoadMigrationFormats(t *testing.T) {
+	migrations, err := newMigrationManager(nil, testFormatMigrationsFS, "testdata/formats").LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations returned an error: %v", err)
+	}
+
+	expected := []struct {
+		name string
+		up   string
+		down string
+		noTx bool
+	}{
+		{name: "create_users", up: "CREATE TABLE users", down: "DROP TABLE users;"},
+		{name: "create_posts", up: "CREATE FUNCTION touch_post()", down: "DROP TABLE posts;\nDROP FUNCTION touch_post();"},
+		{name: "index_posts", up: "CREATE INDEX CONCURRENTLY", down: "DROP INDEX CONCURRENTLY posts_updated_at_idx;", noTx: true},
+		{name: "add_email_to_users", up: "-- Downgrade note", down: "ALTER TABLE users DROP COLUMN email;"},
+	}
+
+	if len(migrations) != len(expected) {
+		t.Fatalf("Expected %d migrations, got %d", len(expected), len(migrations))
+	}
+	for i, want := range expected {
+		mig := migrations[i]
+		if mig.Version != int64(i+1) || mig.Name != want.name {
+			t.Errorf("Migration %d: expected %d_%s, got %d_%s", i, i+1, want.name, mig.Version, mig.Name)
+		}
+		if !strings.HasPrefix(mig.UpSQL, want.up) {
+			t.Errorf("Migration %s: unexpected up SQL %q", want.name, mig.UpSQL)
+		}
+		if mig.DownSQL != want.down {
+			t.Errorf("Migration %s: expected down SQL %q, got %q", want.name, want.down, mig.DownSQL)
+		}
+		if mig.NoTransaction != want.noTx {
+			t.Errorf("Migration %s: expected NoTransaction %v, got %v", want.name, want.noTx, mig.NoTransaction)
+		}
+		if strings.Contains(mig.UpSQL, "+goose") || strings.Contains(mig.UpSQL, "+migrate") {
+			t.Errorf("Migration %s: annotations left in up SQL %q", want.name, mig.UpSQL)
+		}
+	}
+}
+
+// Test parsing migration file content in the supported formats
+func TestParseMigrationContent(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		up      string
+		down    string
+		noTx    bool
+		wantErr string
+	}{
+		{
+			name:    "Native",
+			content: "CREATE TABLE t (id INT);\n\n-- Down\nDROP TABLE t;\n",
+			up:      "CREATE TABLE t (id INT);",
+			down:    "DROP TABLE t;",
+		},
+		{
+			name:    "MarkerInComment",
+			content: "-- Downgrade: not reversible\nCREATE TABLE t (id INT);\n-- Down\n",
+			up:      "-- Downgrade: not reversible\nCREATE TABLE t (id INT);",
+		},
+		{
+			name:    "MissingDown",
+			content: "CREATE TABLE t (id INT); -- Down\n",
+			wantErr: `expected one "-- Down" line, found 0`,
+		},
+		{
+			name:    "RepeatedDown",
+			content: "CREATE TABLE t (id INT);\n-- Down\nDROP TABLE t;\n-- Down\n",
+			wantErr: `expected one "-- Down" line, found 2`,
+		},
+		{
+			name:    "GooseWithoutDown",
+			content: "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY i ON t (id);\n",
+			up:      "CREATE INDEX CONCURRENTLY i ON t (id);",
+			noTx:    true,
+		},
+		{
+			name:    "GooseLowerCase",
+			content: "-- +goose up\nCREATE TABLE t (id INT);\n-- +goose down\nDROP TABLE t;\n",
+			up:      "CREATE TABLE t (id INT);",
+			down:    "DROP TABLE t;",
+		},
+		{
+			name:    "GooseSQLBeforeUp",
+			content: "CREATE TABLE t (id INT);\n-- +goose Up\n",
+			wantErr: "line 1: SQL before the -- +goose Up annotation",
+		},
+		{
+			name:    "GooseDownBeforeUp",
+			content: "-- +goose Down\nDROP TABLE t;\n-- +goose Up\n",
+			wantErr: "line 1: unexpected -- +goose Down",
+		},
+		{
+			name:    "GooseUnsupported",
+			content: "-- +goose Up\n-- +goose ENVSUB ON\nCREATE TABLE t (id INT);\n",
+			wantErr: "line 2: unsupported annotation -- +goose ENVSUB ON",
+		},
+		{
+			name:    "MigrateMissingUp",
+			content: "-- +migrate Down\n",
+			wantErr: "line 1: unexpected -- +migrate Down",
+		},
+		{
+			name:    "MigrateUnsupportedOption",
+			content: "-- +migrate Up concurrently\n",
+			wantErr: `line 1: unsupported option "concurrently"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			up, down, noTx, err := parseMigrationContent(tc.content)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("Expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMigrationContent returned an error: %v", err)
+			}
+
+			if up != tc.up || down != tc.down || noTx != tc.noTx {
+				t.Errorf("Expected (%q, %q, %v), got (%q, %q, %v)", tc.up, tc.down, tc.noTx, up, down, noTx)
+			}
+		})
+	}
+}
+
+// Test the errors for conflicting and incomplete split migration files
+func TestBuildMigration_SplitFiles(t *testing.T) {
+	file := func(filename, content string) migrationFile {
+		f, err := parseMigrationFilename(filename)
+		if err != nil {
+			t.Fatalf("parseMigrationFilename(%q) returned an error: %v", filename, err)
+		}
+		f.Content = content
+		return f
+	}
+
+	testCases := []struct {
+		name    string
+		files   []migrationFile
+		wantErr string
+	}{
+		{
+			name:    "DownOnly",
+			files:   []migrationFile{file("001_users.down.sql", "DROP TABLE users;")},
+			wantErr: "migration 001_users.down.sql has no matching up file",
+		},
+		{
+			name: "NameMismatch",
+			files: []migrationFile{
+				file("001_users.down.sql", "DROP TABLE users;"),
+				file("001_accounts.up.sql", "CREATE TABLE users (id INT);"),
+			},
+			wantErr: "migration files 001_accounts.up.sql and 001_users.down.sql have different names",
+		},
+		{
+			name: "SharedVersion",
+			files: []migrationFile{
+				file("001_users.sql", "CREATE TABLE users (id INT);\n-- Down\n"),
+				file("001_users.up.sql", "CREATE TABLE users (id INT);"),
+			},
+			wantErr: "migration version 1 is declared by more than one file",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := buildMigration(tc.files)
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("Expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
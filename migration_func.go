@@ -0,0 +1,63 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MigrationFunc applies or reverts a Go migration in the migration transaction.
+type MigrationFunc func(ctx context.Context, tx pgx.Tx) error
+
+// RegisterMigration registers a migration implemented in Go, for data
+// migrations that need application logic.
+//
+// Go migrations are interleaved with the SQL migrations from migrationsDir in
+// version order and recorded in the same migrations table. The version must
+// not be used by a migration file or another Go migration. down may be nil for
+// migrations that can't be reverted, in which case rolling them back fails.
+//
+// Register migrations right after New, before connecting.
+//
+// Example:
+//
+//	err := reader.RegisterMigration(4, "hash_api_keys",
+//	    func(ctx context.Context, tx pgx.Tx) error {
+//	        return hashAPIKeys(ctx, tx)
+//	    },
+//	    nil)
+func (r *SQLReader) RegisterMigration(version int64, name string, up, down MigrationFunc) error {
+	if version <= 0 {
+		return fmt.Errorf("registering migration %s: invalid version %d", name, version)
+	}
+	if up == nil {
+		return fmt.Errorf("registering migration %d: up function is nil", version)
+	}
+	if _, ok := findMigration(r.goMigrations, version); ok {
+		return fmt.Errorf("registering migration %d: version already registered", version)
+	}
+
+	r.goMigrations = append(r.goMigrations, migration{
+		Version:  version,
+		Name:     name,
+		UpFunc:   up,
+		DownFunc: down,
+	})
+	return nil
+}
+
+// runMigration calls fn with the migration transaction if it is set, and
+// executes sql otherwise.
+func runMigration(ctx context.Context, db DB, fn MigrationFunc, sql string) error {
+	if fn == nil {
+		_, err := db.Exec(ctx, sql)
+		return err
+	}
+
+	tx, ok := db.(pgx.Tx)
+	if !ok {
+		return fmt.Errorf("database connection is not a transaction")
+	}
+	return fn(ctx, tx)
+}
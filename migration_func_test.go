@@ -0,0 +1,85 @@
+package sqlreader
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test that Go migrations are validated when they are registered
+func TestSQLReader_RegisterMigration(t *testing.T) {
+	reader := &SQLReader{queriesFS: testMigrationsFS, migrationsDir: "testdata/migrations"}
+	up := func(ctx context.Context, tx pgx.Tx) error { return nil }
+
+	if err := reader.RegisterMigration(4, "backfill", up, nil); err != nil {
+		t.Fatalf("RegisterMigration returned an error: %v", err)
+	}
+	if err := reader.RegisterMigration(4, "again", up, nil); err == nil {
+		t.Error("Expected an error for a duplicate version")
+	}
+	if err := reader.RegisterMigration(5, "no_up", nil, nil); err == nil {
+		t.Error("Expected an error for a nil up function")
+	}
+
+	// Versions used by migration files are rejected when migrations are loaded
+	if err := reader.RegisterMigration(2, "posts", up, nil); err != nil {
+		t.Fatalf("RegisterMigration returned an error: %v", err)
+	}
+	if _, err := reader.newMigrator(nil).LoadMigrations(); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("Expected an error for version 2, got %v", err)
+	}
+}
+
+// Test that Go migrations run in version order with the SQL migrations
+func TestConnector_MigrateGoMigration(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	var order []int
+	err := conn.reader.RegisterMigration(4, "lowercase_emails",
+		func(ctx context.Context, tx pgx.Tx) error {
+			order = append(order, 4)
+			_, err := tx.Exec(ctx, "UPDATE users SET email = lower(email)")
+			return err
+		},
+		nil)
+	if err != nil {
+		t.Fatalf("RegisterMigration returned an error: %v", err)
+	}
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2))
+	mock.ExpectExec("ALTER TABLE users ADD COLUMN email").WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(3), "add_email_to_users", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("UPDATE users SET email = lower\\(email\\)").WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(4), "lowercase_emails", pgxmock.AnyArg(), "", pgxmock.AnyArg(), nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := conn.Migrate(context.Background()); err != nil {
+		t.Errorf("Migrate returned an error: %v", err)
+	}
+	if len(order) != 1 {
+		t.Errorf("Expected the Go migration to run once, ran %d times", len(order))
+	}
+
+	// The Go migration has no down function
+	mock.ExpectQuery("SELECT version, name, applied_at").
+		WillReturnRows(appliedRows(t, 1, 2, 3).AddRow(int64(4), "lowercase_emails", time.Now(), "", false))
+
+	if err := conn.Rollback(context.Background()); err == nil || !strings.Contains(err.Error(), "no down function") {
+		t.Errorf("Expected an error for the missing down function, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrMigrationLockTimeout is returned when the migration lock configured with
+// WithMigrationLock isn't acquired within its timeout.
+var ErrMigrationLockTimeout = errors.New("timed out waiting for migration lock")
+
+// migrationLockPoll is how often a waiting instance retries the migration lock.
+var migrationLockPoll = time.Second
+
+// WithMigrationLock makes Migrate, MigrateTo, Rollback, RollbackTo, RollbackAll,
+// RollbackSteps and Repair hold the advisory lock derived from key (see
+// AdvisoryLockKey) while they run, so that only one instance migrates at a time.
+//
+// Other instances wait for the lock, logging a message to the migration
+// logger, and then find the schema up to date. A timeout of zero waits
+// indefinitely; otherwise ErrMigrationLockTimeout is returned once it elapses.
+//
+// On a pool connector the lock is a session-level lock held on a dedicated
+// connection. On a transaction connector it is taken with
+// pg_try_advisory_xact_lock and released when the transaction ends.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithMigrationLock("myapp:migrations", 5*time.Minute))
+func WithMigrationLock(key string, timeout time.Duration) Option {
+	return func(r *SQLReader) {
+		r.migrationLockKey = key
+		r.migrationLockTimeout = timeout
+	}
+}
+
+// WithMigrationLogger sets the logger used for migration messages, such as
+// waiting for the migration lock. The default is slog.Default().
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithMigrationLogger(logger))
+func WithMigrationLogger(logger *slog.Logger) Option {
+	return func(r *SQLReader) {
+		r.migrationLogger = logger
+	}
+}
+
+// migrationLog returns the configured migration logger or slog.Default().
+func (r *SQLReader) migrationLog() *slog.Logger {
+	if r.migrationLogger != nil {
+		return r.migrationLogger
+	}
+	return slog.Default()
+}
+
+// withMigrationLock runs fn while holding the migration lock, or runs it
+// directly if no migration lock is configured.
+func (c *Connector) withMigrationLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	key := c.reader.migrationLockKey
+	if key == "" {
+		return fn(ctx)
+	}
+	lockKey := AdvisoryLockKey(key)
+
+	if tx, isTx := c.db.(pgx.Tx); isTx {
+		if err := c.waitMigrationLock(ctx, tx, lockKey, "pg_try_advisory_xact_lock"); err != nil {
+			return err
+		}
+		return fn(ctx)
+	}
+
+	pool, ok := c.db.(*pgxpool.Pool)
+	if !ok {
+		return fmt.Errorf("unexpected connection type, expected *pgxpool.Pool")
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection for migration lock %s: %w", key, err)
+	}
+
+	if err := c.waitMigrationLock(ctx, conn, lockKey, "pg_try_advisory_lock"); err != nil {
+		conn.Release()
+		return err
+	}
+
+	return unlockAdvisory(ctx, conn, key, lockKey, fn(ctx))
+}
+
+// waitMigrationLock polls tryFunc until the migration lock is acquired, the
+// lock timeout elapses or ctx is done. It logs once when it starts waiting.
+func (c *Connector) waitMigrationLock(ctx context.Context, db DB, lockKey int64, tryFunc string) error {
+	key := c.reader.migrationLockKey
+	timeout := c.reader.migrationLockTimeout
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(migrationLockPoll)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for waiting := false; ; waiting = true {
+		acquired, err := lockAdvisory(ctx, db, lockKey, true, "", tryFunc)
+		if err != nil {
+			return fmt.Errorf("acquiring migration lock %s: %w", key, err)
+		}
+		if acquired {
+			if waiting {
+				c.reader.migrationLog().InfoContext(ctx, "acquired migration lock",
+					slog.String("lock", key), slog.Duration("waited", time.Since(start)))
+			}
+			return nil
+		}
+
+		if !waiting {
+			c.reader.migrationLog().InfoContext(ctx, "waiting for migration lock held by another instance",
+				slog.String("lock", key), slog.Duration("timeout", timeout))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("acquiring migration lock %s: %w", key, ctx.Err())
+		case <-deadline:
+			return fmt.Errorf("%w %s after %s", ErrMigrationLockTimeout, key, timeout)
+		case <-ticker.C:
+		}
+	}
+}
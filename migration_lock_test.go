@@ -0,0 +1,76 @@
+package sqlreader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test that migrations wait for the migration lock and log while waiting
+func TestConnector_MigrationLock(t *testing.T) {
+	defer func(poll time.Duration) { migrationLockPoll = poll }(migrationLockPoll)
+	migrationLockPoll = time.Millisecond
+
+	conn, mock := newMigrationTestConnector(t)
+	var buf bytes.Buffer
+	conn.reader.migrationLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+	conn.reader.migrationLockKey = "app:migrations"
+
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock\\(\\$1\\)").
+		WithArgs(AdvisoryLockKey("app:migrations")).
+		WillReturnRows(pgxmock.NewRows([]string{"acquired"}).AddRow(false))
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock\\(\\$1\\)").
+		WithArgs(AdvisoryLockKey("app:migrations")).
+		WillReturnRows(pgxmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2))
+	mock.ExpectExec("DROP TABLE posts").WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(2)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := conn.Rollback(context.Background()); err != nil {
+		t.Errorf("Rollback returned an error: %v", err)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, "waiting for migration lock") || !strings.Contains(logged, `"lock":"app:migrations"`) {
+		t.Errorf("Expected a waiting message for the lock, got %s", logged)
+	}
+	if !strings.Contains(logged, "acquired migration lock") {
+		t.Errorf("Expected an acquired message, got %s", logged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that waiting for the migration lock gives up after the timeout
+func TestConnector_MigrationLockTimeout(t *testing.T) {
+	defer func(poll time.Duration) { migrationLockPoll = poll }(migrationLockPoll)
+	migrationLockPoll = time.Hour
+
+	conn, mock := newMigrationTestConnector(t)
+	conn.reader.migrationLogger = slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	conn.reader.migrationLockKey = "app:migrations"
+	conn.reader.migrationLockTimeout = time.Millisecond
+
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock\\(\\$1\\)").
+		WithArgs(AdvisoryLockKey("app:migrations")).
+		WillReturnRows(pgxmock.NewRows([]string{"acquired"}).AddRow(false))
+
+	err := conn.Migrate(context.Background())
+	if !errors.Is(err, ErrMigrationLockTimeout) {
+		t.Errorf("Expected ErrMigrationLockTimeout, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// noTransactionDirective marks a migration file that must run outside a
+// transaction, such as one using CREATE INDEX CONCURRENTLY.
+const noTransactionDirective = "-- sqlreader:no-transaction"
+
+// ErrMigrationDirty is returned by Migrate and the rollback methods when a
+// migration that ran outside a transaction failed partway. The database must
+// be fixed by hand and the dirty state cleared with ResolveDirty.
+var ErrMigrationDirty = errors.New("migration is dirty")
+
+// hasNoTransactionDirective reports whether a migration file declares the
+// no-transaction directive on a line of its own.
+func hasNoTransactionDirective(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == noTransactionDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// WithNoTransactionCommits lets migrations declared with the
+// "-- sqlreader:no-transaction" directive run in SingleTransaction mode when
+// other migrations run in the same call.
+//
+// Such a migration commits the migrations before it, and a failure afterwards
+// only rolls back the ones after it, so the call is no longer all-or-nothing.
+// Without this option these calls fail before changing anything. A
+// no-transaction migration that is the only one to run, or any in
+// TransactionPerMigration mode, needs no option.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithNoTransactionCommits())
+func WithNoTransactionCommits() Option {
+	return func(r *SQLReader) {
+		r.noTxCommits = true
+	}
+}
+
+// checkNoTransaction returns an error before anything runs if one of the
+// migrations must run outside a transaction but can't: without a pool, as on
+// transaction connectors and for tenant schemas, or when it would split a
+// single transaction without WithNoTransactionCommits.
+func (m *migrationManager) checkNoTransaction(migrations []migration) error {
+	for _, mig := range migrations {
+		if !mig.NoTransaction {
+			continue
+		}
+		switch {
+		case m.pool == nil || m.searchPath != "":
+			return fmt.Errorf("migration %d must run outside a transaction, which needs a pool connector and isn't supported for tenant schemas", mig.Version)
+		case len(migrations) > 1 && !m.perMigration && !m.splitTx:
+			return fmt.Errorf("migration %d must run outside a transaction, which would commit the single migration transaction partway; "+
+				"use TransactionPerMigration or WithNoTransactionCommits, or run it on its own", mig.Version)
+		}
+	}
+	return nil
+}
+
+// checkDirty returns an error wrapping ErrMigrationDirty if an applied
+// migration is marked dirty.
+func checkDirty(applied map[int64]migration) error {
+	var dirty []int64
+	for version, mig := range applied {
+		if mig.Dirty {
+			dirty = append(dirty, version)
+		}
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	sort.Slice(dirty, func(i, j int) bool { return dirty[i] < dirty[j] })
+	return fmt.Errorf("%w: version %d failed outside a transaction; fix the database and call ResolveDirty", ErrMigrationDirty, dirty[0])
+}
+
+// outsideTx commits the manager's transaction and runs fn for the migration on
+// a dedicated connection. The caller begins a new transaction afterwards.
+// Managers without a pool can't leave their transaction and return an error.
+func (m *migrationManager) outsideTx(ctx context.Context, mig migration, fn func(context.Context, DB, migration) error) error {
+	if m.pool == nil || m.searchPath != "" {
+		return fmt.Errorf("migration %d must run outside a transaction, which requires a pool connector", mig.Version)
+	}
+
+	if err := m.commitTx(ctx); err != nil {
+		return err
+	}
+
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection for migration %d: %w", mig.Version, err)
+	}
+	defer conn.Release()
+
+	return fn(ctx, conn, mig)
+}
+
+// applyOutsideTx records the migration as dirty, runs its up SQL statement by
+// statement and clears the dirty flag. If a statement fails, the record stays
+// dirty so that later runs stop until the database is fixed.
+func (m *migrationManager) applyOutsideTx(ctx context.Context, conn DB, mig migration) error {
+	table := m.tableName()
+	if _, err := conn.Exec(ctx, `
+		INSERT INTO `+table+` (version, name, applied_at, checksum, dirty, applied_by, app_version)
+		VALUES ($1, $2, $3, $4, true, current_user, $5)
+	`, mig.Version, mig.Name, time.Now().UTC(), mig.Checksum, m.recordedAppVersion()); err != nil {
+		return fmt.Errorf("recording migration %d: %w", mig.Version, err)
+	}
+
+	start := time.Now()
+	if err := execStatements(ctx, conn, mig.UpSQL); err != nil {
+		return fmt.Errorf("applying migration %d outside a transaction, marked dirty: %w", mig.Version, err)
+	}
+
+	if _, err := conn.Exec(ctx, "UPDATE "+table+" SET dirty = false, execution_ms = $1 WHERE version = $2",
+		time.Since(start).Milliseconds(), mig.Version); err != nil {
+		return fmt.Errorf("recording migration %d: %w", mig.Version, err)
+	}
+	return nil
+}
+
+// revertOutsideTx marks the migration as dirty, runs its down SQL statement by
+// statement and removes its record.
+func (m *migrationManager) revertOutsideTx(ctx context.Context, conn DB, mig migration) error {
+	table := m.tableName()
+	if _, err := conn.Exec(ctx, "UPDATE "+table+" SET dirty = true WHERE version = $1", mig.Version); err != nil {
+		return fmt.Errorf("marking migration %d dirty: %w", mig.Version, err)
+	}
+
+	if err := execStatements(ctx, conn, mig.DownSQL); err != nil {
+		return fmt.Errorf("rolling back migration %d outside a transaction, marked dirty: %w", mig.Version, err)
+	}
+
+	if _, err := conn.Exec(ctx, "DELETE FROM "+table+" WHERE version = $1", mig.Version); err != nil {
+		return fmt.Errorf("removing migration record %d: %w", mig.Version, err)
+	}
+	return nil
+}
+
+// execStatements runs each statement of sql separately. PostgreSQL runs the
+// statements of a single multi-statement query in an implicit transaction,
+// which commands like CREATE INDEX CONCURRENTLY refuse.
+func execStatements(ctx context.Context, db DB, sql string) error {
+	for _, stmt := range splitStatements(sql) {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits SQL on semicolons that aren't inside quotes,
+// dollar-quoted strings or comments. Statements consisting only of comments
+// are dropped.
+func splitStatements(sql string) []string {
+	var statements []string
+	start := 0
+	hasCode := false
+
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(sql[start:end]))
+		}
+		start = end + 1
+		hasCode = false
+	}
+
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+		case c == '\'' || c == '"':
+			hasCode = true
+			for i++; i < len(sql); i++ {
+				if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '$':
+			hasCode = true
+			if tag, ok := dollarQuoteTag(sql[i:]); ok {
+				if end := strings.Index(sql[i+len(tag):], tag); end >= 0 {
+					i += len(tag) + end + len(tag) - 1
+				} else {
+					i = len(sql)
+				}
+			}
+		case c == ';':
+			flush(i)
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			hasCode = true
+		}
+	}
+	if start < len(sql) {
+		flush(len(sql))
+	}
+
+	return statements
+}
+
+// dollarQuoteTag returns the opening tag of a dollar-quoted string, such as
+// "$$" or "$body$", at the start of s.
+func dollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1], true
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 1 && c >= '0' && c <= '9'):
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// ResolveDirty clears the dirty state left by a migration that failed outside
+// a transaction, after the database has been fixed by hand.
+//
+// If applied is true the migration is kept as applied, for example after
+// finishing its statements manually. Otherwise its record is removed so that
+// the next Migrate runs it again.
+//
+// Example:
+//
+//	// The concurrent index build failed; the invalid index was dropped by hand
+//	if err := conn.ResolveDirty(ctx, false); err != nil {
+//	    log.Fatal(err)
+//	}
+func (c *Connector) ResolveDirty(ctx context.Context, applied bool) error {
+	return c.runMigrations(ctx, "repair", func(m *migrationManager, ctx context.Context) error {
+		return m.ResolveDirty(ctx, applied)
+	})
+}
+
+// ResolveDirty marks dirty migrations as applied or removes their records.
+func (m *migrationManager) ResolveDirty(ctx context.Context, applied bool) error {
+	query := "DELETE FROM " + m.tableName() + " WHERE dirty"
+	if applied {
+		query = "UPDATE " + m.tableName() + " SET dirty = false WHERE dirty"
+	}
+
+	if _, err := m.db.Exec(ctx, query); err != nil {
+		return fmt.Errorf("resolving dirty migrations: %w", err)
+	}
+	return nil
+}
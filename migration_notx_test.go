@@ -0,0 +1,203 @@
+package sqlreader
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+//go:embed testdata/notx/*.sql
+var testNoTxMigrationsFS embed.FS
+
+// Test that SQL is split on semicolons outside quotes, comments and dollar quotes
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sql      string
+		expected []string
+	}{
+		{
+			name:     "Simple statements",
+			sql:      "CREATE INDEX CONCURRENTLY a ON t (x);\nVACUUM t;",
+			expected: []string{"CREATE INDEX CONCURRENTLY a ON t (x)", "VACUUM t"},
+		},
+		{
+			name:     "Trailing statement without semicolon",
+			sql:      "SELECT 1; SELECT 2",
+			expected: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:     "Quoted semicolons",
+			sql:      `INSERT INTO t VALUES ('a;''b'); SELECT ";" FROM t`,
+			expected: []string{`INSERT INTO t VALUES ('a;''b')`, `SELECT ";" FROM t`},
+		},
+		{
+			name: "Comments",
+			sql:  "-- sqlreader:no-transaction\n-- drop; it\nVACUUM t; /* ; */ VACUUM u;\n-- trailing;",
+			expected: []string{
+				"-- sqlreader:no-transaction\n-- drop; it\nVACUUM t",
+				"/* ; */ VACUUM u",
+			},
+		},
+		{
+			name:     "Dollar quotes",
+			sql:      "DO $body$ BEGIN PERFORM 1; END $body$; SELECT $1::int",
+			expected: []string{"DO $body$ BEGIN PERFORM 1; END $body$", "SELECT $1::int"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statements := splitStatements(tc.sql)
+			if !reflect.DeepEqual(statements, tc.expected) {
+				t.Errorf("Expected %q, got %q", tc.expected, statements)
+			}
+		})
+	}
+}
+
+// Test that the no-transaction directive is read from migration files
+func TestMigrationManager_LoadNoTransaction(t *testing.T) {
+	migrations, err := newMigrationManager(nil, testNoTxMigrationsFS, "testdata/notx").LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations returned an error: %v", err)
+	}
+
+	if len(migrations) != 2 || migrations[0].NoTransaction || !migrations[1].NoTransaction {
+		t.Errorf("Expected only migration 2 to run outside a transaction, got %+v", migrations)
+	}
+}
+
+// Test that transaction connectors refuse migrations that must run outside a
+// transaction before applying anything
+func TestConnector_MigrateNoTransactionInTx(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+	conn.reader.queriesFS = testNoTxMigrationsFS
+	conn.reader.migrationsDir = "testdata/notx"
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(migrationRows())
+
+	err := conn.Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "migration 2 must run outside a transaction, which needs a pool connector") {
+		t.Errorf("Expected an error for migration 2, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that a single transaction isn't split by a no-transaction migration unless allowed
+func TestMigrationManager_NoTransactionSingleTransaction(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("Failed to create mock pool: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(migrationRows())
+
+	tx, err := mock.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin returned an error: %v", err)
+	}
+	m := newMigrationManager(tx, testNoTxMigrationsFS, "testdata/notx")
+	m.pool = mock
+
+	err = m.Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "would commit the single migration transaction partway") {
+		t.Errorf("Expected the single transaction to be protected, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations returned an error: %v", err)
+	}
+	if err := m.checkNoTransaction(migrations[1:]); err != nil {
+		t.Errorf("Expected a lone no-transaction migration to be allowed, got %v", err)
+	}
+
+	m.splitTx = true
+	if err := m.checkNoTransaction(migrations); err != nil {
+		t.Errorf("Expected WithNoTransactionCommits to allow the migrations, got %v", err)
+	}
+	m.splitTx, m.perMigration = false, true
+	if err := m.checkNoTransaction(migrations); err != nil {
+		t.Errorf("Expected TransactionPerMigration to allow the migrations, got %v", err)
+	}
+}
+
+// Test that a failing statement outside a transaction leaves the migration dirty
+func TestMigrationManager_ApplyOutsideTxFailure(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	m := newMigrationManager(nil, testNoTxMigrationsFS, "testdata/notx")
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations returned an error: %v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO schema_migrations \\(version, name, applied_at, checksum, dirty,").
+		WithArgs(int64(2), "index_users_email", pgxmock.AnyArg(), migrations[1].Checksum, nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY users_email_idx ON users \\(email\\)$").
+		WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY users_lower_email_idx").
+		WillReturnError(errors.New("could not create unique index"))
+
+	err = m.applyOutsideTx(context.Background(), mock, migrations[1])
+	if err == nil || !strings.Contains(err.Error(), "marked dirty") {
+		t.Errorf("Expected a dirty migration error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that dirty migrations block Migrate until they are resolved
+func TestConnector_MigrateDirty(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").
+		WillReturnRows(migrationRows().AddRow(int64(1), "create_users", time.Now(), "", true))
+
+	if err := conn.Migrate(context.Background()); !errors.Is(err, ErrMigrationDirty) {
+		t.Errorf("Expected ErrMigrationDirty, got %v", err)
+	}
+
+	mock.ExpectExec("DELETE FROM schema_migrations WHERE dirty").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := conn.ResolveDirty(context.Background(), false); err != nil {
+		t.Errorf("ResolveDirty returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
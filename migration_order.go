@@ -0,0 +1,75 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// OutOfOrderPolicy selects what Migrate does with pending migrations that are
+// older than the latest applied migration, which typically appear after
+// merging branches.
+type OutOfOrderPolicy int
+
+const (
+	// OutOfOrderApply applies them silently, as earlier releases did. This is
+	// the default.
+	OutOfOrderApply OutOfOrderPolicy = iota
+
+	// OutOfOrderWarn logs a warning to the migration logger and applies them.
+	OutOfOrderWarn
+
+	// OutOfOrderError refuses to migrate, returning an error wrapping
+	// ErrMigrationOutOfOrder.
+	OutOfOrderError
+)
+
+// ErrMigrationOutOfOrder is returned when pending migrations are older than
+// the latest applied migration and the policy is OutOfOrderError.
+var ErrMigrationOutOfOrder = errors.New("migrations are out of order")
+
+// WithOutOfOrderPolicy sets what Migrate and MigrateTo do with pending
+// migrations older than the latest applied migration. By default they are
+// applied; choose OutOfOrderError to catch branches merged late.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithOutOfOrderPolicy(sqlreader.OutOfOrderError))
+func WithOutOfOrderPolicy(policy OutOfOrderPolicy) Option {
+	return func(r *SQLReader) {
+		r.outOfOrder = policy
+	}
+}
+
+// checkOrder applies the out-of-order policy to the pending migrations up to
+// target that are older than the latest applied migration.
+func (m *migrationManager) checkOrder(ctx context.Context, migrations []migration, applied map[int64]migration, target int64) error {
+	if m.outOfOrder == OutOfOrderApply {
+		return nil
+	}
+
+	latest := latestVersion(applied)
+	var versions []string
+	for _, mig := range migrations {
+		if mig.Version >= latest || mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; !ok {
+			versions = append(versions, strconv.FormatInt(mig.Version, 10))
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	if m.outOfOrder == OutOfOrderWarn {
+		m.logger.WarnContext(ctx, "applying migrations older than the latest applied migration",
+			slog.String("versions", strings.Join(versions, ", ")), slog.Int64("latest", latest))
+		return nil
+	}
+	return fmt.Errorf("%w: %s older than the latest applied version %d", ErrMigrationOutOfOrder, strings.Join(versions, ", "), latest)
+}
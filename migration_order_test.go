@@ -0,0 +1,80 @@
+package sqlreader
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+//go:embed testdata/timestamps/*.sql
+var testTimestampMigrationsFS embed.FS
+
+// Test that timestamp versions beyond the INTEGER range are parsed
+func TestMigrationManager_LoadTimestampVersions(t *testing.T) {
+	migrations, err := newMigrationManager(nil, testTimestampMigrationsFS, "testdata/timestamps").LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations returned an error: %v", err)
+	}
+
+	if len(migrations) != 1 || migrations[0].Version != 20261016120000 || migrations[0].Name != "add_audit_log" {
+		t.Errorf("Expected version 20261016120000 add_audit_log, got %+v", migrations)
+	}
+}
+
+// Test the out-of-order policies for pending migrations older than the latest applied one
+func TestConnector_MigrateOutOfOrder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		policy  OutOfOrderPolicy
+		applies bool
+		warns   bool
+	}{
+		{name: "Default", applies: true},
+		{name: "Error", policy: OutOfOrderError},
+		{name: "Warn", policy: OutOfOrderWarn, applies: true, warns: true},
+		{name: "Apply", policy: OutOfOrderApply, applies: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, mock := newMigrationTestConnector(t)
+			var buf bytes.Buffer
+			conn.reader.migrationLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+			conn.reader.outOfOrder = tc.policy
+
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+				WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+				WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+			mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 3))
+			if tc.applies {
+				mock.ExpectExec("CREATE TABLE posts").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mock.ExpectExec("INSERT INTO schema_migrations").
+					WithArgs(int64(2), "create_posts", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), nil).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			}
+
+			err := conn.Migrate(context.Background())
+			if tc.applies && err != nil {
+				t.Errorf("Migrate returned an error: %v", err)
+			}
+			if !tc.applies && !errors.Is(err, ErrMigrationOutOfOrder) {
+				t.Errorf("Expected ErrMigrationOutOfOrder, got %v", err)
+			}
+
+			if warned := strings.Contains(buf.String(), `"versions":"2"`); warned != tc.warns {
+				t.Errorf("Expected warning %v, got log %s", tc.warns, buf.String())
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
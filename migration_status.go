@@ -0,0 +1,118 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MigrationState describes whether a migration has been applied.
+type MigrationState string
+
+const (
+	// MigrationApplied means the migration file has been applied.
+	MigrationApplied MigrationState = "applied"
+
+	// MigrationPending means the migration file has not been applied yet.
+	MigrationPending MigrationState = "pending"
+
+	// MigrationMissingFile means the migration was applied but its file no longer exists.
+	MigrationMissingFile MigrationState = "missing-file"
+
+	// MigrationModified means the migration was applied from a file that has since changed.
+	MigrationModified MigrationState = "modified"
+
+	// MigrationDirty means the migration failed partway while running outside a transaction.
+	MigrationDirty MigrationState = "dirty"
+)
+
+// MigrationInfo reports the state of a single migration.
+type MigrationInfo struct {
+	Version   int64          // The migration version number
+	Name      string         // The migration name, taken from the file if it exists
+	State     MigrationState // Whether the migration has been applied
+	AppliedAt time.Time      // When the migration was applied, zero if it wasn't
+}
+
+// MigrationStatus returns the state of every migration, merging the migration
+// files with the records of the migrations table, ordered by version.
+//
+// It doesn't create the migrations table; if it doesn't exist yet, every
+// migration is reported as pending.
+//
+// On a connector returned by ForTenant it reports the migrations of the tenant
+// schema. With WithTenantSchemas, call it on a tenant connector: the reader's
+// own connector returns an error, as its migrations run in every tenant schema.
+//
+// Example:
+//
+//	status, err := conn.MigrationStatus(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, m := range status {
+//	    fmt.Printf("%03d %-30s %s\n", m.Version, m.Name, m.State)
+//	}
+func (c *Connector) MigrationStatus(ctx context.Context) ([]MigrationInfo, error) {
+	if c.schema != "" {
+		return c.tenantStatus(ctx)
+	}
+	if c.reader.tenantQuery != "" {
+		return nil, fmt.Errorf("reading migration status: migrations are applied to each tenant schema; use ForTenant to read the status of one")
+	}
+	return c.reader.newMigrator(c.db).Status(ctx)
+}
+
+// Status merges the migration files with the applied migrations.
+// A migration whose recorded name or checksum differs from its file is reported as modified.
+func (m *migrationManager) Status(ctx context.Context) ([]MigrationInfo, error) {
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := m.db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", m.tableName()).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("checking migrations table: %w", err)
+	}
+
+	applied := make(map[int64]migration)
+	if exists {
+		if applied, err = m.GetAppliedMigrations(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]MigrationInfo, 0, len(migrations))
+	for _, mig := range migrations {
+		info := MigrationInfo{Version: mig.Version, Name: mig.Name, State: MigrationPending}
+		if record, ok := applied[mig.Version]; ok {
+			info.State = MigrationApplied
+			info.AppliedAt = record.AppliedAt
+			if record.Name != mig.Name || (record.Checksum != "" && record.Checksum != mig.Checksum) {
+				info.State = MigrationModified
+			}
+			if record.Dirty {
+				info.State = MigrationDirty
+			}
+			delete(applied, mig.Version)
+		}
+		status = append(status, info)
+	}
+
+	for _, record := range applied {
+		status = append(status, MigrationInfo{
+			Version:   record.Version,
+			Name:      record.Name,
+			State:     MigrationMissingFile,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Version < status[j].Version
+	})
+
+	return status, nil
+}
@@ -0,0 +1,156 @@
+package sqlreader
+
+import (
+	"context"
+	"embed"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+//go:embed testdata/migrations/*.sql
+var testMigrationsFS embed.FS
+
+// Test that MigrationStatus merges migration files with applied migrations
+func TestConnector_MigrationStatus(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{queriesFS: testMigrationsFS, migrationsDir: "testdata/migrations"}
+	conn := &Connector{db: mock, reader: reader}
+
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT to_regclass\\(\\$1\\) IS NOT NULL").
+		WithArgs("schema_migrations").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT version, name, applied_at.*FROM schema_migrations.*").
+		WillReturnRows(migrationRows().
+			AddRow(int64(0), "bootstrap", appliedAt, "", false).
+			AddRow(int64(1), "create_users", appliedAt, "", false).
+			AddRow(int64(2), "create_comments", appliedAt, "", false).
+			AddRow(int64(3), "add_email_to_users", appliedAt, "0000", false))
+
+	status, err := conn.MigrationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("MigrationStatus returned an error: %v", err)
+	}
+
+	expected := []MigrationInfo{
+		{Version: 0, Name: "bootstrap", State: MigrationMissingFile, AppliedAt: appliedAt},
+		{Version: 1, Name: "create_users", State: MigrationApplied, AppliedAt: appliedAt},
+		{Version: 2, Name: "create_posts", State: MigrationModified, AppliedAt: appliedAt},
+		{Version: 3, Name: "add_email_to_users", State: MigrationModified, AppliedAt: appliedAt},
+	}
+	if len(status) != len(expected) {
+		t.Fatalf("Expected %d migrations, got %d: %+v", len(expected), len(status), status)
+	}
+	for i, info := range status {
+		if info != expected[i] {
+			t.Errorf("Migration %d: expected %+v, got %+v", i, expected[i], info)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that MigrationStatus reports every migration as pending without a migrations table
+func TestConnector_MigrationStatusWithoutTable(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{queriesFS: testMigrationsFS, migrationsDir: "testdata/migrations"}
+	conn := &Connector{db: mock, reader: reader}
+
+	mock.ExpectQuery("SELECT to_regclass").
+		WithArgs("schema_migrations").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+
+	status, err := conn.MigrationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("MigrationStatus returned an error: %v", err)
+	}
+	if len(status) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(status))
+	}
+	for _, info := range status {
+		if info.State != MigrationPending {
+			t.Errorf("Expected migration %d to be pending, got %s", info.Version, info.State)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that MigrationStatus reads the tenant schema's migrations table on a tenant connector
+func TestConnector_MigrationStatusTenant(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader, err := New(testMigrationsFS, "testdata/migrations", "testdata/migrations")
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	mock.ExpectQuery("SELECT current_setting\\('search_path'\\)").
+		WillReturnRows(pgxmock.NewRows([]string{"search_path"}).AddRow(`"$user", public`))
+	mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+		WithArgs(`"acme"`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT to_regclass").
+		WithArgs("schema_migrations").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1))
+	mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+		WithArgs(`"$user", public`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	status, err := reader.Connect(mock).ForTenant("acme").MigrationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("MigrationStatus returned an error: %v", err)
+	}
+	if len(status) != 3 || status[0].State != MigrationApplied || status[1].State != MigrationPending {
+		t.Errorf("Unexpected tenant status: %+v", status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that MigrationStatus refuses to guess a schema when tenant schemas are configured
+func TestConnector_MigrationStatusTenantSchemas(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader, err := New(testMigrationsFS, "testdata/migrations", "testdata/migrations", WithTenantSchemas("list_tenant_schemas"))
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	_, err = reader.Connect(mock).MigrationStatus(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "use ForTenant") {
+		t.Errorf("Expected an error pointing to ForTenant, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package sqlreader
+
+import "github.com/jackc/pgx/v5"
+
+// WithMigrationTable sets the name of the table that records applied
+// migrations, instead of schema_migrations. Use it when the default name
+// collides with another migration tool or another service in the same database.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithMigrationTable("billing_migrations"))
+func WithMigrationTable(name string) Option {
+	return func(r *SQLReader) {
+		r.migrationTable = name
+	}
+}
+
+// WithMigrationSchema sets the schema of the migrations table, which is
+// created if it doesn't exist. By default the table is resolved through the
+// search_path.
+//
+// It can't be combined with tenant schemas, which each track their migrations
+// in a table of their own schema: New returns an error when WithTenantSchemas
+// is also set, and so do migrations run through ForTenant.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithMigrationSchema("billing"))
+func WithMigrationSchema(schema string) Option {
+	return func(r *SQLReader) {
+		r.migrationTableSchema = schema
+	}
+}
+
+// WithMigrationAppVersion sets the application version recorded in the
+// app_version column of each migration applied, such as a release tag or
+// commit hash.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithMigrationAppVersion(buildinfo.Version))
+func WithMigrationAppVersion(version string) Option {
+	return func(r *SQLReader) {
+		r.appVersion = version
+	}
+}
+
+// migrationTableName returns the sanitized migrations table configured with
+// WithMigrationTable and WithMigrationSchema, or "" for the default.
+func (r *SQLReader) migrationTableName() string {
+	if r.migrationTable == "" && r.migrationTableSchema == "" {
+		return ""
+	}
+
+	name := r.migrationTable
+	if name == "" {
+		name = "schema_migrations"
+	}
+	if r.migrationTableSchema == "" {
+		return pgx.Identifier{name}.Sanitize()
+	}
+	return pgx.Identifier{r.migrationTableSchema, name}.Sanitize()
+}
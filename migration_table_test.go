@@ -0,0 +1,101 @@
+package sqlreader
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test that the migrations table name is sanitized and qualified with its schema
+func TestSQLReader_MigrationTableName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "Default",
+			expected: "schema_migrations",
+		},
+		{
+			name:     "Table",
+			opts:     []Option{WithMigrationTable("billing_migrations")},
+			expected: `"billing_migrations"`,
+		},
+		{
+			name:     "Schema",
+			opts:     []Option{WithMigrationSchema("billing")},
+			expected: `"billing"."schema_migrations"`,
+		},
+		{
+			name:     "Schema and table",
+			opts:     []Option{WithMigrationSchema("Billing"), WithMigrationTable(`my"migrations`)},
+			expected: `"Billing"."my""migrations"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := &SQLReader{}
+			for _, opt := range tc.opts {
+				opt(reader)
+			}
+
+			if table := reader.newMigrator(nil).tableName(); table != tc.expected {
+				t.Errorf("Expected table %s, got %s", tc.expected, table)
+			}
+		})
+	}
+}
+
+// Test that Initialize creates the configured schema and upgrades existing tables
+func TestMigrationManager_InitializeCustomTable(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{}
+	WithMigrationSchema("billing")(reader)
+	WithMigrationTable("billing_migrations")(reader)
+
+	table := regexp.QuoteMeta(`"billing"."billing_migrations"`)
+	mock.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "billing";\s*CREATE TABLE IF NOT EXISTS ` + table +
+		`(?s).*ALTER TABLE ` + table + `.*ADD COLUMN IF NOT EXISTS execution_ms BIGINT.*ADD COLUMN IF NOT EXISTS app_version TEXT`).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+
+	if err := reader.newMigrator(mock).Initialize(context.Background()); err != nil {
+		t.Errorf("Initialize returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that applied migrations record the execution time, user and application version
+func TestConnector_MigrateRecordsAppVersion(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+	conn.reader.appVersion = "v1.2.3"
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2))
+	mock.ExpectExec("ALTER TABLE users ADD COLUMN email").WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations \(version, name, applied_at, checksum, execution_ms, applied_by, app_version\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, current_user, \$6\)`).
+		WithArgs(int64(3), "add_email_to_users", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), "v1.2.3").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := conn.Migrate(context.Background()); err != nil {
+		t.Errorf("Migrate returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// newMigrationTestConnector returns a transaction connector over the test migrations
+func newMigrationTestConnector(t *testing.T) (*Connector, pgxmock.PgxConnIface) {
+	t.Helper()
+
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	t.Cleanup(func() { mock.Close(context.Background()) })
+
+	reader := &SQLReader{queriesFS: testMigrationsFS, migrationsDir: "testdata/migrations"}
+	return &Connector{db: mock, reader: reader}, mock
+}
+
+// migrationRows returns empty rows with the columns read by GetAppliedMigrations
+func migrationRows() *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"version", "name", "applied_at", "checksum", "dirty"})
+}
+
+// appliedRows returns schema_migrations rows for the given test migration
+// versions, stamped with the checksums of the test migration files
+func appliedRows(t *testing.T, versions ...int64) *pgxmock.Rows {
+	t.Helper()
+
+	migrations, err := newMigrationManager(nil, testMigrationsFS, "testdata/migrations").LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load test migrations: %v", err)
+	}
+
+	rows := migrationRows()
+	for _, v := range versions {
+		mig, _ := findMigration(migrations, v)
+		rows.AddRow(v, mig.Name, time.Now(), mig.Checksum, false)
+	}
+	return rows
+}
+
+// Test that MigrateTo applies pending migrations up to the target version only
+func TestConnector_MigrateTo(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1))
+	mock.ExpectExec("CREATE TABLE posts").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(2), "create_posts", pgxmock.AnyArg(), checksum("CREATE TABLE posts (id SERIAL PRIMARY KEY, title TEXT, user_id INTEGER REFERENCES users(id));"), pgxmock.AnyArg(), nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := conn.MigrateTo(context.Background(), 2); err != nil {
+		t.Errorf("MigrateTo returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that RollbackTo reverts newer migrations, newest first
+func TestConnector_RollbackTo(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2, 3))
+	mock.ExpectExec("ALTER TABLE users DROP COLUMN email").WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(3)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DROP TABLE posts").WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(2)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := conn.RollbackTo(context.Background(), 1); err != nil {
+		t.Errorf("RollbackTo returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that RollbackSteps reverts at most the applied migrations
+func TestConnector_RollbackSteps(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2))
+	mock.ExpectExec("DROP TABLE posts").WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(2)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DROP TABLE users").WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(1)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := conn.RollbackSteps(context.Background(), 5); err != nil {
+		t.Errorf("RollbackSteps returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that RollbackAll reverts every migration while RollbackTo(0) needs a version 0 file
+func TestConnector_RollbackAll(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2))
+	mock.ExpectExec("DROP TABLE posts").WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(2)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DROP TABLE users").WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(1)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := conn.RollbackAll(context.Background()); err != nil {
+		t.Errorf("RollbackAll returned an error: %v", err)
+	}
+	if err := conn.RollbackTo(context.Background(), 0); !errors.Is(err, ErrMigrationNotFound) {
+		t.Errorf("Expected ErrMigrationNotFound for version 0, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that MigrateTo refuses to target an applied version behind the latest one
+func TestConnector_MigrateToBehind(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2, 3))
+
+	err := conn.MigrateTo(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "newer migrations are applied up to version 3") {
+		t.Errorf("Expected an error for a target behind the latest version, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that targeting a version without a migration file fails before changing anything
+func TestConnector_MigrateToUnknownVersion(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+
+	err := conn.MigrateTo(context.Background(), 7)
+	if !errors.Is(err, ErrMigrationNotFound) {
+		t.Errorf("Expected ErrMigrationNotFound, got %v", err)
+	}
+
+	err = conn.RollbackTo(context.Background(), 7)
+	if !errors.Is(err, ErrMigrationNotFound) {
+		t.Errorf("Expected ErrMigrationNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that Migrate refuses to run when applied migration files were edited
+func TestConnector_MigrateModified(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").
+		WillReturnRows(migrationRows().
+			AddRow(int64(1), "create_users", time.Now(), "stale", false).
+			AddRow(int64(2), "create_posts", time.Now(), "", false).
+			AddRow(int64(3), "add_email_to_users", time.Now(), "stale", false))
+
+	err := conn.Migrate(context.Background())
+
+	var modified *ErrMigrationModified
+	if !errors.As(err, &modified) {
+		t.Fatalf("Expected *ErrMigrationModified, got %v", err)
+	}
+	if len(modified.Versions) != 2 || modified.Versions[0] != 1 || modified.Versions[1] != 3 {
+		t.Errorf("Expected modified versions [1 3], got %v", modified.Versions)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that Repair re-stamps stale and missing checksums only
+func TestConnector_Repair(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	rows := appliedRows(t, 1)
+	rows.AddRow(int64(2), "create_posts", time.Now(), "", false)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(rows)
+	mock.ExpectExec("UPDATE schema_migrations").
+		WithArgs("create_posts", pgxmock.AnyArg(), int64(2)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := conn.Repair(context.Background()); err != nil {
+		t.Errorf("Repair returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
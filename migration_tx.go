@@ -0,0 +1,141 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MigrationTxMode selects how pool connectors group migrations into transactions.
+type MigrationTxMode int
+
+const (
+	// SingleTransaction runs all migrations of a call in one transaction, so a
+	// failure leaves the database as it was. This is the default.
+	//
+	// Migrations declared with the "-- sqlreader:no-transaction" directive
+	// would have to commit that transaction partway, so calls running one
+	// together with other migrations fail unless WithNoTransactionCommits
+	// is set.
+	SingleTransaction MigrationTxMode = iota
+
+	// TransactionPerMigration commits each migration in its own transaction, so
+	// a failure keeps the migrations that succeeded before it.
+	TransactionPerMigration
+)
+
+// WithMigrationTxMode sets how Migrate, MigrateTo and the rollback methods
+// group migrations into transactions on pool connectors.
+//
+// Transaction connectors always run migrations in their transaction. On pool
+// connectors the mode applies to each tenant schema in turn.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithMigrationTxMode(sqlreader.TransactionPerMigration))
+func WithMigrationTxMode(mode MigrationTxMode) Option {
+	return func(r *SQLReader) {
+		r.migrationTxMode = mode
+	}
+}
+
+// migrationPool is the part of *pgxpool.Pool that migrations use to commit
+// along the way and to run migrations outside a transaction.
+type migrationPool interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// MigrationError reports the migration at which Migrate, MigrateTo or a
+// rollback method stopped, and the version the database was left at.
+//
+// Use errors.As to get the details:
+//
+//	var migErr *sqlreader.MigrationError
+//	if errors.As(err, &migErr) {
+//	    log.Printf("migration %d failed, database at version %d", migErr.Version, migErr.Current)
+//	}
+type MigrationError struct {
+	Version int64  // The migration that failed
+	Name    string // The name of the migration that failed
+	Current int64  // The version the database is at once the transaction is rolled back, 0 if none
+	Err     error  // The underlying error
+}
+
+// Error implements the error interface.
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %d (%s) failed, database is at version %d: %v", e.Version, e.Name, e.Current, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// step applies or reverts a single migration with inTx, or with outside for
+// migrations that run outside a transaction. after is the version the database
+// is at once the step succeeds. In TransactionPerMigration mode the step is
+// committed right away.
+func (m *migrationManager) step(ctx context.Context, mig migration, after int64, outside, inTx func(context.Context, DB, migration) error) error {
+	if mig.NoTransaction {
+		if err := m.outsideTx(ctx, mig, outside); err != nil {
+			return err
+		}
+		m.version, m.committed = after, after
+		return m.beginTx(ctx)
+	}
+
+	if err := inTx(ctx, m.db, mig); err != nil {
+		return err
+	}
+	m.version = after
+
+	if m.perMigration && m.pool != nil {
+		if err := m.commitTx(ctx); err != nil {
+			return err
+		}
+		return m.beginTx(ctx)
+	}
+	return nil
+}
+
+// commitTx commits the manager's transaction.
+func (m *migrationManager) commitTx(ctx context.Context) error {
+	tx, ok := m.db.(pgx.Tx)
+	if !ok {
+		return fmt.Errorf("database connection is not a transaction")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing migrations up to version %d: %w", m.version, err)
+	}
+
+	m.committed = m.version
+	return nil
+}
+
+// beginTx starts a new transaction for the next migrations.
+func (m *migrationManager) beginTx(ctx context.Context) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting migration transaction: %w", err)
+	}
+
+	m.db = tx
+	if err := m.setSearchPath(ctx); err != nil {
+		return fmt.Errorf("setting search_path for migration transaction: %w", err)
+	}
+	return nil
+}
+
+// setSearchPath sets the tenant search_path for the current transaction, if
+// the manager migrates a tenant schema.
+func (m *migrationManager) setSearchPath(ctx context.Context) error {
+	if m.searchPath == "" {
+		return nil
+	}
+	_, err := m.db.Exec(ctx, "SELECT set_config('search_path', $1, true)", m.searchPath)
+	return err
+}
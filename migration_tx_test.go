@@ -0,0 +1,127 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test that TransactionPerMigration commits each migration and reports where the run stopped
+func TestMigrationManager_TransactionPerMigration(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("Failed to create mock pool: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1))
+	mock.ExpectExec("CREATE TABLE posts").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(2), "create_posts", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE users ADD COLUMN email").
+		WillReturnError(errors.New("column \"email\" already exists"))
+
+	tx, err := mock.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin returned an error: %v", err)
+	}
+	m := newMigrationManager(tx, testMigrationsFS, "testdata/migrations")
+	m.pool = mock
+	m.perMigration = true
+
+	err = m.Migrate(context.Background())
+
+	var migErr *MigrationError
+	if !errors.As(err, &migErr) {
+		t.Fatalf("Expected *MigrationError, got %v", err)
+	}
+	if migErr.Version != 3 || migErr.Name != "add_email_to_users" || migErr.Current != 2 {
+		t.Errorf("Expected migration 3 to fail at version 2, got %+v", migErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that transactions begun for a tenant schema get its search_path again
+func TestMigrationManager_TransactionPerMigrationTenant(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("Failed to create mock pool: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2))
+	mock.ExpectExec("ALTER TABLE users ADD COLUMN email").WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(3), "add_email_to_users", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(`"acme"`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	tx, err := mock.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin returned an error: %v", err)
+	}
+	m := newMigrationManager(tx, testMigrationsFS, "testdata/migrations")
+	m.pool = mock
+	m.perMigration = true
+	m.searchPath = `"acme"`
+
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Errorf("Migrate returned an error: %v", err)
+	}
+	if m.committed != 3 {
+		t.Errorf("Expected version 3 to be committed, got %d", m.committed)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that a failure in a single transaction reports the version before the run
+func TestConnector_MigrateSingleTransactionFailure(t *testing.T) {
+	conn, mock := newMigrationTestConnector(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1))
+	mock.ExpectExec("CREATE TABLE posts").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(2), "create_posts", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("ALTER TABLE users ADD COLUMN email").
+		WillReturnError(errors.New("column \"email\" already exists"))
+
+	err := conn.Migrate(context.Background())
+
+	var migErr *MigrationError
+	if !errors.As(err, &migErr) {
+		t.Fatalf("Expected *MigrationError, got %v", err)
+	}
+	if migErr.Version != 3 || migErr.Current != 1 {
+		t.Errorf("Expected migration 3 to fail at version 1, got %+v", migErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
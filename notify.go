@@ -0,0 +1,253 @@
+package sqlreader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Reconnection delays used by subscriptions after losing their connection.
+const (
+	listenMinBackoff = 100 * time.Millisecond
+	listenMaxBackoff = 5 * time.Second
+)
+
+// Notification is a message received on a LISTEN channel.
+type Notification struct {
+	Channel string // The channel the notification was sent on
+	Payload string // The notification payload
+	PID     uint32 // Process ID of the notifying backend
+}
+
+// Decode unmarshals a JSON-encoded payload into v.
+func (n Notification) Decode(v interface{}) error {
+	if err := json.Unmarshal([]byte(n.Payload), v); err != nil {
+		return fmt.Errorf("decoding %s notification: %w", n.Channel, err)
+	}
+	return nil
+}
+
+// Subscription delivers notifications received by Listen.
+type Subscription struct {
+	// C receives the notifications. It is closed when the subscription ends.
+	C <-chan Notification
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	err    error
+}
+
+// Close stops the subscription and releases its connection.
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Err returns the most recent connection error while the subscription is
+// reconnecting, or nil once it is listening again.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Listen subscribes to one or more notification channels.
+//
+// It acquires a dedicated connection from the pool, issues LISTEN for each
+// channel and delivers notifications on Subscription.C until ctx is cancelled
+// or Close is called. If the connection is lost, Listen reconnects and issues
+// LISTEN again; notifications sent while disconnected are lost.
+//
+// Listen is only available on connectors created by ConnectPool or ConnectCluster.
+//
+// Example:
+//
+//	sub, err := conn.Listen(ctx, "cache_invalidation")
+//	if err != nil {
+//	    return err
+//	}
+//	defer sub.Close()
+//
+//	for n := range sub.C {
+//	    fmt.Printf("%s: %s\n", n.Channel, n.Payload)
+//	}
+func (c *Connector) Listen(ctx context.Context, channels ...string) (*Subscription, error) {
+	pool, ok := c.db.(*pgxpool.Pool)
+	if !ok {
+		return nil, fmt.Errorf("unexpected connection type, expected *pgxpool.Pool")
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("listening: no channels given")
+	}
+
+	conn, err := listenConn(ctx, pool, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	notifications := make(chan Notification)
+	s := &Subscription{
+		C:      notifications,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go s.run(ctx, pool, conn, channels, notifications)
+	return s, nil
+}
+
+// ListenFunc subscribes to one or more notification channels and calls fn for
+// every notification until ctx is cancelled or fn returns an error.
+//
+// It behaves like Listen and blocks until the subscription ends. It returns nil
+// when ctx is cancelled, and otherwise the error returned by fn.
+//
+// Example:
+//
+//	err := conn.ListenFunc(ctx, func(n sqlreader.Notification) error {
+//	    var event CacheEvent
+//	    if err := n.Decode(&event); err != nil {
+//	        return err
+//	    }
+//	    cache.Invalidate(ctx, event.Tags...)
+//	    return nil
+//	}, "cache_invalidation")
+func (c *Connector) ListenFunc(ctx context.Context, fn func(Notification) error, channels ...string) error {
+	sub, err := c.Listen(ctx, channels...)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	for n := range sub.C {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Notify sends a notification on a channel using pg_notify.
+//
+// String and []byte payloads are sent as they are; any other payload is
+// encoded as JSON. Inside a transaction, the notification is delivered when
+// the transaction commits.
+//
+// Example:
+//
+//	err := conn.Notify(ctx, "cache_invalidation", CacheEvent{Tags: []string{"users"}})
+func (c *Connector) Notify(ctx context.Context, channel string, payload interface{}) error {
+	var text string
+	switch p := payload.(type) {
+	case string:
+		text = p
+	case []byte:
+		text = string(p)
+	default:
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("encoding %s notification payload: %w", channel, err)
+		}
+		text = string(encoded)
+	}
+
+	db := c.db
+	if _, isTx := db.(pgx.Tx); !isTx {
+		if tx, ok := TxFromContext(ctx); ok {
+			db = tx
+		}
+	}
+
+	if _, err := db.Exec(ctx, "SELECT pg_notify($1, $2)", channel, text); err != nil {
+		return fmt.Errorf("notifying %s: %w", channel, err)
+	}
+	return nil
+}
+
+// listenConn acquires a connection, detaches it from the pool and issues LISTEN
+// for each channel. The caller is responsible for closing the connection.
+func listenConn(ctx context.Context, pool *pgxpool.Pool, channels []string) (*pgx.Conn, error) {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring listen connection: %w", err)
+	}
+
+	// The connection is detached so its LISTEN state never leaks to other pool users
+	conn := pooled.Hijack()
+	for _, channel := range channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			conn.Close(context.Background())
+			return nil, fmt.Errorf("listening on %s: %w", channel, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// run receives notifications and reconnects after connection loss until ctx is done.
+func (s *Subscription) run(ctx context.Context, pool *pgxpool.Pool, conn *pgx.Conn, channels []string, out chan<- Notification) {
+	defer close(s.done)
+	defer close(out)
+
+	backoff := listenMinBackoff
+	for {
+		if conn != nil {
+			err := receive(ctx, conn, out)
+			conn.Close(context.Background())
+			conn = nil
+			if ctx.Err() != nil {
+				return
+			}
+			s.setErr(err)
+			backoff = listenMinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		var err error
+		if conn, err = listenConn(ctx, pool, channels); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.setErr(err)
+			backoff = min(backoff*2, listenMaxBackoff)
+			continue
+		}
+		s.setErr(nil)
+	}
+}
+
+// receive forwards notifications from conn to out until an error occurs.
+func receive(ctx context.Context, conn *pgx.Conn, out chan<- Notification) error {
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for notification: %w", err)
+		}
+
+		select {
+		case out <- Notification{Channel: n.Channel, Payload: n.Payload, PID: n.PID}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// setErr records the most recent subscription error.
+func (s *Subscription) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
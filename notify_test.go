@@ -0,0 +1,63 @@
+package sqlreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test sending notifications with string and JSON payloads
+func TestConnector_Notify(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	connector := &Connector{db: mock, reader: &SQLReader{}}
+
+	mock.ExpectExec("SELECT pg_notify").
+		WithArgs("events", "plain").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("SELECT pg_notify").
+		WithArgs("events", `{"tags":["users"]}`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	if err := connector.Notify(context.Background(), "events", "plain"); err != nil {
+		t.Errorf("Notify with string payload returned an error: %v", err)
+	}
+	payload := struct {
+		Tags []string `json:"tags"`
+	}{Tags: []string{"users"}}
+	if err := connector.Notify(context.Background(), "events", payload); err != nil {
+		t.Errorf("Notify with JSON payload returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+
+	if _, err := connector.Listen(context.Background(), "events"); err == nil {
+		t.Error("Expected Listen on a transaction connector to fail")
+	}
+}
+
+// Test decoding JSON notification payloads
+func TestNotification_Decode(t *testing.T) {
+	var event struct {
+		Tags []string `json:"tags"`
+	}
+
+	n := Notification{Channel: "events", Payload: `{"tags":["users","posts"]}`}
+	if err := n.Decode(&event); err != nil {
+		t.Fatalf("Decode returned an error: %v", err)
+	}
+	if len(event.Tags) != 2 || event.Tags[1] != "posts" {
+		t.Errorf("Unexpected decoded payload: %+v", event)
+	}
+
+	if err := (Notification{Channel: "events", Payload: "plain"}).Decode(&event); err == nil {
+		t.Error("Expected Decode of a non-JSON payload to fail")
+	}
+}
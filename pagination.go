@@ -0,0 +1,462 @@
+package sqlreader
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrInvalidCursor is returned by Paginate when a cursor is malformed, has an
+// invalid signature or was issued for a different query.
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// WithCursorKey sets the key used to sign pagination cursors.
+//
+// Without this option a random key is generated by New, so cursors are only
+// valid within the running process. Services running several instances behind
+// a load balancer should share a key.
+func WithCursorKey(key []byte) Option {
+	return func(r *SQLReader) {
+		r.cursorKey = key
+	}
+}
+
+// newCursorKey generates a random key for signing pagination cursors.
+func newCursorKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("generating cursor key: %v", err))
+	}
+	return key
+}
+
+// Page is a single page of results returned by Paginate.
+type Page struct {
+	result *CachedResult
+
+	// Next is the cursor of the following page, or empty on the last page.
+	// An empty page reached by paging backward links forward again.
+	Next string
+
+	// Prev is the cursor of the preceding page, or empty on the first page.
+	// An empty page reached by paging forward links backward again.
+	Prev string
+}
+
+// Rows returns the rows of the page in sort order.
+func (p *Page) Rows() pgx.Rows {
+	return newCachedRows(p.result)
+}
+
+// Len returns the number of rows in the page.
+func (p *Page) Len() int {
+	return len(p.result.Rows)
+}
+
+// Paginate returns a page of at most limit rows of a named query using keyset pagination.
+//
+// The query must declare its sort keys with a "-- paginate:" annotation. The
+// keys must be output columns of the query that together identify a row
+// uniquely and are never NULL. Unquoted keys are folded to lower case and
+// quoted keys keep their case. The query itself must not contain ORDER BY or
+// LIMIT clauses; Paginate wraps it, adds the keyset predicate as parameters
+// following args, and fetches one extra row to detect further pages.
+//
+// Pass an empty cursor for the first page, then Page.Next or Page.Prev.
+// Cursors are opaque, signed and bound to the query name.
+//
+//	-- name: list_posts
+//	-- paginate: created_at DESC, id DESC
+//	SELECT id, title, created_at FROM posts WHERE user_id = $1
+//
+// Example:
+//
+//	page, err := conn.Paginate(ctx, "list_posts", cursor, 20, userID)
+//	if err != nil {
+//	    return err
+//	}
+//	rows := page.Rows()
+//	for rows.Next() {
+//	    // scan the post
+//	}
+//	nextCursor := page.Next
+func (c *Connector) Paginate(ctx context.Context, name, cursor string, limit int, args ...interface{}) (*Page, error) {
+	return c.loader.paginate(ctx, name, cursor, limit, args...)
+}
+
+// sortKey is a single column of a "-- paginate:" annotation.
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// sortKeys parses the query's "-- paginate:" annotation.
+func (m queryMeta) sortKeys() ([]sortKey, error) {
+	value, ok := m.annotation("paginate")
+	if !ok {
+		return nil, fmt.Errorf("missing paginate annotation")
+	}
+
+	var keys []sortKey
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.Fields(field)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid sort key %q", strings.TrimSpace(field))
+		}
+
+		column, err := sortKeyColumn(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		key := sortKey{column: column}
+		if len(parts) == 2 {
+			switch strings.ToUpper(parts[1]) {
+			case "ASC":
+			case "DESC":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction %q", parts[1])
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// sortKeyColumn returns the output column name a sort key refers to.
+// Unquoted names are folded to lower case like PostgreSQL identifiers; quoted
+// names keep their case. Qualified names such as "p.id" aren't output columns
+// of the wrapped query and are rejected.
+func sortKeyColumn(name string) (string, error) {
+	if len(name) > 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`), nil
+	}
+	if strings.ContainsAny(name, `."`) {
+		return "", fmt.Errorf("invalid sort key %q: use the name of an output column and alias it in the query if needed", name)
+	}
+	return strings.ToLower(name), nil
+}
+
+// pageColumn returns a sort key column qualified with the page alias, quoted
+// when it isn't a plain lower-case identifier.
+func pageColumn(column string) string {
+	for i, r := range column {
+		if (r < 'a' || r > 'z') && r != '_' && (i == 0 || (r < '0' || r > '9') && r != '$') {
+			return "page." + pgx.Identifier{column}.Sanitize()
+		}
+	}
+	return "page." + column
+}
+
+// missingSortKey explains an undefined column error from a paginated query.
+// It describes the result of the named query and returns an error naming the
+// first sort key that isn't one of its columns. It returns nil if err has
+// another cause or the result can't be described, as in a failed transaction.
+func (l *queryLoader) missingSortKey(ctx context.Context, name, query string, args []interface{}, keys []sortKey, err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42703" {
+		return nil
+	}
+
+	var fields []pgconn.FieldDescription
+	err = l.withSettings(ctx, l.conn(ctx, name, query), func(db DB) error {
+		rows, err := db.Query(ctx, "SELECT * FROM ("+query+") AS page LIMIT 0", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		fields = append([]pgconn.FieldDescription(nil), rows.FieldDescriptions()...)
+		for rows.Next() {
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil
+	}
+	return checkSortKeys(fields, keys)
+}
+
+// checkSortKeys returns an error naming the first sort key that isn't a
+// column of the result.
+func checkSortKeys(fields []pgconn.FieldDescription, keys []sortKey) error {
+	for _, key := range keys {
+		found := false
+		for _, fd := range fields {
+			if fd.Name == key.column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(fields))
+			for i, fd := range fields {
+				names[i] = fd.Name
+			}
+			return fmt.Errorf("sort key %q is not a column of the result, which has columns %s", key.column, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// pageCursor is the signed content of a pagination cursor.
+type pageCursor struct {
+	Query    string    `json:"q"`
+	Backward bool      `json:"b,omitempty"`
+	Values   []*string `json:"v"`
+}
+
+// encodeCursor serializes and signs a cursor.
+func encodeCursor(key []byte, c pageCursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("encoding cursor: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
+
+// decodeCursor verifies and deserializes a cursor issued for the named query.
+func decodeCursor(key []byte, name, cursor string, keyCount int) (pageCursor, error) {
+	var c pageCursor
+
+	enc := base64.RawURLEncoding
+	encodedPayload, encodedSig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return c, ErrInvalidCursor
+	}
+	payload, err := enc.DecodeString(encodedPayload)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	sig, err := enc.DecodeString(encodedSig)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(payload, &c); err != nil || c.Query != name || len(c.Values) != keyCount {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// pageSQL wraps a query with the keyset predicate, ordering and limit.
+// Predicate parameters are numbered from firstParam; the limit follows them.
+func pageSQL(query string, keys []sortKey, withPredicate, backward bool, firstParam int) string {
+	var b strings.Builder
+	b.WriteString("SELECT * FROM (\n")
+	b.WriteString(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	b.WriteString("\n) AS page")
+
+	param := firstParam
+	if withPredicate {
+		// (a > $1) OR (a = $1 AND b > $2) OR ...
+		var terms []string
+		for i, key := range keys {
+			var parts []string
+			for j := 0; j < i; j++ {
+				parts = append(parts, fmt.Sprintf("%s = $%d", pageColumn(keys[j].column), firstParam+j))
+			}
+			op := ">"
+			if key.desc != backward {
+				op = "<"
+			}
+			parts = append(parts, fmt.Sprintf("%s %s $%d", pageColumn(key.column), op, firstParam+i))
+			terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		}
+		b.WriteString("\nWHERE ")
+		b.WriteString(strings.Join(terms, " OR "))
+		param += len(keys)
+	}
+
+	var order []string
+	for _, key := range keys {
+		dir := "ASC"
+		if key.desc != backward {
+			dir = "DESC"
+		}
+		order = append(order, fmt.Sprintf("%s %s", pageColumn(key.column), dir))
+	}
+	b.WriteString("\nORDER BY ")
+	b.WriteString(strings.Join(order, ", "))
+	fmt.Fprintf(&b, "\nLIMIT $%d", param)
+
+	return b.String()
+}
+
+// keyValues returns the text representation of the sort key columns of a row.
+func keyValues(fields []pgconn.FieldDescription, row [][]byte, keys []sortKey) ([]*string, error) {
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	values := make([]*string, len(keys))
+	for i, key := range keys {
+		idx := -1
+		for j, fd := range fields {
+			if fd.Name == key.column {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("sort key %s is not a column of the result", key.column)
+		}
+
+		raw, fd := row[idx], fields[idx]
+		if raw == nil {
+			continue
+		}
+		if fd.Format == pgtype.TextFormatCode {
+			text := string(raw)
+			values[i] = &text
+			continue
+		}
+
+		t, ok := m.TypeForOID(fd.DataTypeOID)
+		if !ok {
+			return nil, fmt.Errorf("sort key %s has unsupported type %d", key.column, fd.DataTypeOID)
+		}
+		decoded, err := t.Codec.DecodeValue(m, fd.DataTypeOID, fd.Format, raw)
+		if err != nil {
+			return nil, fmt.Errorf("decoding sort key %s: %w", key.column, err)
+		}
+		encoded, err := m.Encode(fd.DataTypeOID, pgtype.TextFormatCode, decoded, nil)
+		if err != nil {
+			return nil, fmt.Errorf("encoding sort key %s: %w", key.column, err)
+		}
+		text := string(encoded)
+		values[i] = &text
+	}
+
+	return values, nil
+}
+
+// paginate implements Connector.Paginate.
+func (l *queryLoader) paginate(ctx context.Context, name, cursor string, limit int, args ...interface{}) (*Page, error) {
+	query := l.querier.get(name)
+	meta := l.querier.metadata(name)
+	if limit <= 0 {
+		return nil, fmt.Errorf("paginating %s: limit must be positive, got %d", name, limit)
+	}
+
+	keys, err := meta.sortKeys()
+	if err != nil {
+		return nil, fmt.Errorf("paginating %s: %w", name, err)
+	}
+
+	var pos pageCursor
+	if cursor != "" {
+		if pos, err = decodeCursor(l.cursorKey, name, cursor, len(keys)); err != nil {
+			return nil, fmt.Errorf("paginating %s: %w", name, err)
+		}
+	}
+
+	sql := pageSQL(query, keys, cursor != "", pos.Backward, len(args)+1)
+	pageArgs := append([]interface{}(nil), args...)
+	for _, value := range pos.Values {
+		if value == nil {
+			pageArgs = append(pageArgs, nil)
+		} else {
+			pageArgs = append(pageArgs, *value)
+		}
+	}
+	pageArgs = append(pageArgs, limit+1)
+
+	start := time.Now()
+	result := &CachedResult{}
+	err = l.withSettings(ctx, l.conn(ctx, name, query), func(db DB) error {
+		rows, err := db.Query(ctx, sql, pageArgs...)
+		if err != nil {
+			return err
+		}
+		result, err = collectRows(rows)
+		return err
+	})
+	l.observe(ctx, name, args, start, int64(len(result.Rows)), err)
+	if err != nil {
+		if keyErr := l.missingSortKey(ctx, name, query, args, keys, err); keyErr != nil {
+			return nil, fmt.Errorf("paginating %s: %v: %w", name, keyErr, l.wrapError(name, PhaseExecute, sql, pageArgs, err))
+		}
+		return nil, l.wrapError(name, PhaseExecute, sql, pageArgs, err)
+	}
+
+	more := len(result.Rows) > limit
+	if more {
+		result.Rows = result.Rows[:limit]
+	}
+	if pos.Backward {
+		for i, j := 0, len(result.Rows)-1; i < j; i, j = i+1, j-1 {
+			result.Rows[i], result.Rows[j] = result.Rows[j], result.Rows[i]
+		}
+	}
+
+	page := &Page{result: result}
+	if len(result.Rows) == 0 {
+		// Past either end, the cursor leads back the way we came
+		if cursor != "" {
+			back := pageCursor{Query: name, Backward: !pos.Backward, Values: pos.Values}
+			encoded, err := encodeCursor(l.cursorKey, back)
+			if err != nil {
+				return nil, fmt.Errorf("paginating %s: %w", name, err)
+			}
+			if pos.Backward {
+				page.Next = encoded
+			} else {
+				page.Prev = encoded
+			}
+		}
+		return page, nil
+	}
+
+	// Moving forward, a previous page exists whenever we started from a cursor;
+	// moving backward, a next page always exists.
+	hasNext, hasPrev := more, cursor != ""
+	if pos.Backward {
+		hasNext, hasPrev = true, more
+	}
+
+	if hasNext {
+		values, err := keyValues(result.Fields, result.Rows[len(result.Rows)-1], keys)
+		if err != nil {
+			return nil, fmt.Errorf("paginating %s: %w", name, err)
+		}
+		if page.Next, err = encodeCursor(l.cursorKey, pageCursor{Query: name, Values: values}); err != nil {
+			return nil, fmt.Errorf("paginating %s: %w", name, err)
+		}
+	}
+	if hasPrev {
+		values, err := keyValues(result.Fields, result.Rows[0], keys)
+		if err != nil {
+			return nil, fmt.Errorf("paginating %s: %w", name, err)
+		}
+		if page.Prev, err = encodeCursor(l.cursorKey, pageCursor{Query: name, Backward: true, Values: values}); err != nil {
+			return nil, fmt.Errorf("paginating %s: %w", name, err)
+		}
+	}
+
+	return page, nil
+}
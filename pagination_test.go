@@ -0,0 +1,233 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test generation of the keyset pagination SQL
+func TestPageSQL(t *testing.T) {
+	keys := []sortKey{{column: "created_at", desc: true}, {column: "id", desc: true}}
+	query := "SELECT id, created_at FROM posts WHERE user_id = $1;"
+
+	tests := []struct {
+		name          string
+		withPredicate bool
+		backward      bool
+		expected      string
+	}{
+		{
+			name: "first page",
+			expected: `SELECT * FROM (
+SELECT id, created_at FROM posts WHERE user_id = $1
+) AS page
+ORDER BY page.created_at DESC, page.id DESC
+LIMIT $2`,
+		},
+		{
+			name:          "next page",
+			withPredicate: true,
+			expected: `SELECT * FROM (
+SELECT id, created_at FROM posts WHERE user_id = $1
+) AS page
+WHERE (page.created_at < $2) OR (page.created_at = $2 AND page.id < $3)
+ORDER BY page.created_at DESC, page.id DESC
+LIMIT $4`,
+		},
+		{
+			name:          "previous page",
+			withPredicate: true,
+			backward:      true,
+			expected: `SELECT * FROM (
+SELECT id, created_at FROM posts WHERE user_id = $1
+) AS page
+WHERE (page.created_at > $2) OR (page.created_at = $2 AND page.id > $3)
+ORDER BY page.created_at ASC, page.id ASC
+LIMIT $4`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if sql := pageSQL(query, keys, tc.withPredicate, tc.backward, 2); sql != tc.expected {
+				t.Errorf("SQL mismatch:\nExpected: %s\nActual: %s", tc.expected, sql)
+			}
+		})
+	}
+}
+
+// Test cursor signing and validation
+func TestCursor_Signature(t *testing.T) {
+	key := []byte("secret")
+	value := "42"
+
+	cursor, err := encodeCursor(key, pageCursor{Query: "list_posts", Values: []*string{&value}})
+	if err != nil {
+		t.Fatalf("encodeCursor returned an error: %v", err)
+	}
+
+	decoded, err := decodeCursor(key, "list_posts", cursor, 1)
+	if err != nil || *decoded.Values[0] != "42" {
+		t.Errorf("Expected cursor to decode, got %+v, %v", decoded, err)
+	}
+
+	if _, err := decodeCursor([]byte("other"), "list_posts", cursor, 1); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a wrong key, got %v", err)
+	}
+	if _, err := decodeCursor(key, "list_users", cursor, 1); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for another query, got %v", err)
+	}
+	if _, err := decodeCursor(key, "list_posts", "x"+cursor, 1); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a tampered cursor, got %v", err)
+	}
+}
+
+// Test paging forward through a named query
+func TestQueryLoader_Paginate(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	qs := &queryStore{queries: make(map[string]string)}
+	err = qs.parseQueries("sql/posts.sql", `-- name: list_posts
+-- paginate: id DESC
+SELECT id FROM posts WHERE user_id = $1`)
+	if err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+	loader := &queryLoader{db: mock, querier: qs, cursorKey: []byte("secret")}
+
+	column := pgconn.FieldDescription{Name: "id", DataTypeOID: pgtype.Int8OID, Format: pgtype.TextFormatCode}
+	mock.ExpectQuery("ORDER BY page.id DESC").
+		WithArgs(7, 3).
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(column).AddRow(9).AddRow(8).AddRow(5))
+	mock.ExpectQuery("WHERE \\(page.id < \\$2\\)").
+		WithArgs(7, "8", 3).
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(column).AddRow(5))
+
+	first, err := loader.paginate(context.Background(), "list_posts", "", 2, 7)
+	if err != nil {
+		t.Fatalf("paginate returned an error: %v", err)
+	}
+	if first.Len() != 2 || first.Next == "" || first.Prev != "" {
+		t.Fatalf("Unexpected first page: %d rows, next %q, prev %q", first.Len(), first.Next, first.Prev)
+	}
+
+	second, err := loader.paginate(context.Background(), "list_posts", first.Next, 2, 7)
+	if err != nil {
+		t.Fatalf("paginate returned an error: %v", err)
+	}
+	if second.Len() != 1 || second.Next != "" || second.Prev == "" {
+		t.Errorf("Unexpected second page: %d rows, next %q, prev %q", second.Len(), second.Next, second.Prev)
+	}
+
+	rows := second.Rows()
+	var id int64
+	if !rows.Next() || rows.Scan(&id) != nil || id != 5 {
+		t.Errorf("Expected second page to contain id 5, got %d", id)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test parsing of quoted, mixed-case and qualified sort keys
+func TestQueryMeta_SortKeys(t *testing.T) {
+	meta := queryMeta{annotations: map[string]string{"paginate": `"createdAt" DESC, Id`}}
+	keys, err := meta.sortKeys()
+	if err != nil {
+		t.Fatalf("sortKeys returned an error: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != (sortKey{column: "createdAt", desc: true}) || keys[1] != (sortKey{column: "id"}) {
+		t.Errorf("Unexpected sort keys %+v", keys)
+	}
+
+	sql := pageSQL("SELECT 1", keys, false, false, 1)
+	if !strings.Contains(sql, `ORDER BY page."createdAt" DESC, page.id ASC`) {
+		t.Errorf("Expected quoted sort key in %s", sql)
+	}
+
+	meta = queryMeta{annotations: map[string]string{"paginate": "p.id"}}
+	if _, err := meta.sortKeys(); err == nil {
+		t.Error("Expected an error for a qualified sort key")
+	}
+}
+
+// Test that an empty page links back and unknown sort keys are reported
+func TestQueryLoader_PaginateEdges(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	qs := &queryStore{queries: make(map[string]string)}
+	err = qs.parseQueries("sql/posts.sql", `-- name: list_posts
+-- paginate: id DESC
+SELECT id FROM posts
+
+-- name: list_titles
+-- paginate: created_at
+SELECT id, title FROM posts`)
+	if err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+	loader := &queryLoader{db: mock, querier: qs, cursorKey: []byte("secret")}
+
+	value := "3"
+	backward, err := encodeCursor(loader.cursorKey, pageCursor{Query: "list_posts", Backward: true, Values: []*string{&value}})
+	if err != nil {
+		t.Fatalf("encodeCursor returned an error: %v", err)
+	}
+
+	column := pgconn.FieldDescription{Name: "id", DataTypeOID: pgtype.Int8OID, Format: pgtype.TextFormatCode}
+	mock.ExpectQuery("WHERE \\(page.id > \\$1\\)").
+		WithArgs("3", 3).
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(column))
+	mock.ExpectQuery("WHERE \\(page.id < \\$1\\)").
+		WithArgs("3", 3).
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(column).AddRow(2))
+	mock.ExpectQuery("ORDER BY page.created_at ASC").
+		WithArgs(3).
+		WillReturnError(&pgconn.PgError{Code: "42703", Message: "column page.created_at does not exist"})
+	mock.ExpectQuery("SELECT \\* FROM \\(SELECT id, title FROM posts\\) AS page LIMIT 0").
+		WillReturnRows(pgxmock.NewRowsWithColumnDefinition(column, pgconn.FieldDescription{Name: "title", DataTypeOID: pgtype.TextOID}))
+
+	empty, err := loader.paginate(context.Background(), "list_posts", backward, 2)
+	if err != nil {
+		t.Fatalf("paginate returned an error: %v", err)
+	}
+	if empty.Len() != 0 || empty.Next == "" || empty.Prev != "" {
+		t.Fatalf("Unexpected empty page: %d rows, next %q, prev %q", empty.Len(), empty.Next, empty.Prev)
+	}
+
+	forward, err := loader.paginate(context.Background(), "list_posts", empty.Next, 2)
+	if err != nil {
+		t.Fatalf("paginate returned an error: %v", err)
+	}
+	if forward.Len() != 1 {
+		t.Errorf("Expected one row paging forward from the empty page, got %d", forward.Len())
+	}
+
+	_, err = loader.paginate(context.Background(), "list_titles", "", 2)
+	if err == nil || !strings.Contains(err.Error(), `sort key "created_at" is not a column of the result, which has columns id, title`) {
+		t.Errorf("Expected missing sort key error, got %v", err)
+	}
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Errorf("Expected the missing sort key error to wrap the *QueryError, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
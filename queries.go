@@ -3,6 +3,7 @@ package sqlreader
 import (
 	"embed"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -10,6 +11,28 @@ import (
 // It's loaded at initialization time from SQL files in the embedded filesystem.
 type queryStore struct {
 	queries map[string]string
+	meta    map[string]queryMeta
+}
+
+// queryMeta holds the annotations declared for a named query.
+//
+// Annotations are comment lines placed directly below the name line:
+//
+//	-- name: get_user_by_email
+//	-- redact: 1
+//	SELECT * FROM users WHERE email = $1
+type queryMeta struct {
+	annotations map[string]string
+
+	file   string // Path of the .sql file that declares the query
+	line   int    // 1-based line of the first character of the SQL text
+	column int    // 1-based column of the first character of the SQL text
+}
+
+// annotation returns the value of the named annotation and whether it was declared.
+func (m queryMeta) annotation(key string) (string, bool) {
+	value, ok := m.annotations[key]
+	return value, ok
 }
 
 // newQueryStore creates a new query store and loads all SQL queries from the
@@ -24,6 +47,7 @@ type queryStore struct {
 func newQueryStore(fs embed.FS, dirPath string) (*queryStore, error) {
 	qs := &queryStore{
 		queries: make(map[string]string),
+		meta:    make(map[string]queryMeta),
 	}
 
 	// Read files from the embedded filesystem
@@ -39,7 +63,7 @@ func newQueryStore(fs embed.FS, dirPath string) (*queryStore, error) {
 				return nil, fmt.Errorf("reading SQL file %s: %w", entry.Name(), err)
 			}
 
-			if err := qs.parseQueries(string(content)); err != nil {
+			if err := qs.parseQueries(dirPath+"/"+entry.Name(), string(content)); err != nil {
 				return nil, fmt.Errorf("parsing queries from %s: %w", entry.Name(), err)
 			}
 		}
@@ -51,9 +75,14 @@ func newQueryStore(fs embed.FS, dirPath string) (*queryStore, error) {
 // parseQueries parses SQL queries from file content.
 // Queries are expected to be separated by blank lines and start with
 // a comment line in the format "-- name: query_name".
-func (qs *queryStore) parseQueries(content string) error {
+// The file path is recorded with each query so errors can point back to the source.
+func (qs *queryStore) parseQueries(file, content string) error {
 	queries := strings.Split(content, "\n\n")
+	nextLine := 1
 	for _, query := range queries {
+		startLine := nextLine
+		nextLine += strings.Count(query, "\n") + 2
+
 		if strings.TrimSpace(query) == "" {
 			continue
 		}
@@ -75,13 +104,66 @@ func (qs *queryStore) parseQueries(content string) error {
 		}
 
 		name := parts[2]
-		queryText := strings.Join(lines[1:], "\n")
+		body := lines[1:]
+
+		// Collect annotation lines between the name line and the SQL text
+		meta := queryMeta{annotations: make(map[string]string)}
+		for len(body) > 0 {
+			key, value, ok := parseAnnotation(body[0])
+			if !ok {
+				break
+			}
+			meta.annotations[key] = value
+			body = body[1:]
+		}
+
+		if value, ok := meta.annotations["cache"]; ok {
+			if _, err := parseCacheTTL(value); err != nil {
+				return fmt.Errorf("query %s: %w", name, err)
+			}
+		}
+
+		queryText := strings.Join(body, "\n")
+
+		// Locate the first character of the trimmed SQL text in the file
+		leading := queryText[:len(queryText)-len(strings.TrimLeft(queryText, " \t\r\n"))]
+		meta.file = file
+		meta.line = startLine + len(lines) - len(body) + strings.Count(leading, "\n")
+		meta.column = len(leading) - strings.LastIndex(leading, "\n")
+
 		qs.queries[name] = strings.TrimSpace(queryText)
+		if qs.meta == nil {
+			qs.meta = make(map[string]queryMeta)
+		}
+		qs.meta[name] = meta
 	}
 
 	return nil
 }
 
+// parseAnnotation parses a query annotation line in the format "-- key: value"
+// or "-- key". Keys are lower-case words; any other comment is not an annotation.
+func parseAnnotation(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "--") {
+		return "", "", false
+	}
+
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+	key, value, _ = strings.Cut(rest, ":")
+	key = strings.TrimSpace(key)
+	if key == "" || key == "name" {
+		return "", "", false
+	}
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && r != '_' && r != '-' {
+			return "", "", false
+		}
+	}
+
+	return key, strings.TrimSpace(value), true
+}
+
 // get returns the SQL query for the given name.
 // Panics if the query is not found.
 // This function is designed to fail fast during development and testing,
@@ -93,3 +175,19 @@ func (qs *queryStore) get(name string) string {
 	}
 	return query
 }
+
+// names returns the names of all queries in sorted order.
+func (qs *queryStore) names() []string {
+	names := make([]string, 0, len(qs.queries))
+	for name := range qs.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// metadata returns the annotations declared for the given query.
+// Queries without annotations return an empty queryMeta.
+func (qs *queryStore) metadata(name string) queryMeta {
+	return qs.meta[name]
+}
@@ -2,7 +2,8 @@ package sqlreader
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -11,14 +12,21 @@ import (
 // queryLoader wraps a database connection and query store to provide
 // convenient methods for executing queries
 type queryLoader struct {
-	db      dbConn
-	querier *queryStore
+	db        DB
+	querier   *queryStore
+	slowLog   *slowQueryLog
+	errorMap  *ErrorMapping
+	cluster   *cluster
+	cache     Cache
+	cursorKey []byte
+	settings  []setting
 }
 
-// dbConn is an interface that abstracts the database connection.
+// DB is an interface that abstracts the database connection.
 // It can be either a *pgxpool.Pool or pgx.Tx, allowing the queryLoader
-// to work with both connection pools and transactions.
-type dbConn interface {
+// to work with both connection pools and transactions. Test doubles such as
+// sqlreadertest.Recorder implement it too, see SQLReader.Connect.
+type DB interface {
 	// Exec executes a SQL query that doesn't return rows
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
 
@@ -33,24 +41,62 @@ type dbConn interface {
 // It gets the SQL query by name from the query store and executes it with the provided arguments.
 func (l *queryLoader) exec(ctx context.Context, name string, args ...interface{}) error {
 	query := l.querier.get(name)
-	_, err := l.db.Exec(ctx, query, args...)
+	start := time.Now()
+
+	var tag pgconn.CommandTag
+	conn := l.conn(ctx, name, query)
+	err := l.withSettings(ctx, conn, func(db DB) error {
+		var err error
+		tag, err = db.Exec(ctx, query, args...)
+		return err
+	})
+	l.observe(ctx, name, args, start, tag.RowsAffected(), err)
 	if err != nil {
-		return fmt.Errorf("executing %s: %w", name, err)
+		return l.wrapError(name, PhaseExecute, query, args, err)
 	}
 
+	l.invalidate(ctx, conn, name)
 	return nil
 }
 
 // queryRow loads and executes a query that returns a single row.
 // It gets the SQL query by name from the query store, executes it with the provided arguments,
 // and passes the result row to the scanner function.
+// Because pgx reports query failures when the row is scanned, errors coming from
+// PostgreSQL are attributed to the execute phase and all others to the scan phase.
 func (l *queryLoader) queryRow(ctx context.Context, name string, scanner func(pgx.Row) error, args ...interface{}) error {
 	query := l.querier.get(name)
-	row := l.db.QueryRow(ctx, query, args...)
-	if err := scanner(row); err != nil {
-		return fmt.Errorf("scanning %s result: %w", name, err)
+	start := time.Now()
+
+	phase := PhaseExecute
+	conn := l.conn(ctx, name, query)
+	err := l.withSettings(ctx, conn, func(db DB) error {
+		var row pgx.Row
+		cached, ok, err := l.cachedQuery(ctx, db, name, query, args)
+		switch {
+		case err != nil:
+			return err
+		case ok:
+			row = cached
+		default:
+			row = db.QueryRow(ctx, query, args...)
+		}
+
+		if err := scanner(row); err != nil {
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				phase = PhaseScan
+			}
+			return err
+		}
+		return nil
+	})
+	l.observe(ctx, name, args, start, rowCount(err), err)
+	if err != nil {
+		return l.wrapError(name, phase, query, args, err)
 	}
 
+	l.invalidate(ctx, conn, name)
 	return nil
 }
 
@@ -59,15 +105,142 @@ func (l *queryLoader) queryRow(ctx context.Context, name string, scanner func(pg
 // and passes the result rows to the scanner function.
 func (l *queryLoader) queryRows(ctx context.Context, name string, scanner func(pgx.Rows) error, args ...interface{}) error {
 	query := l.querier.get(name)
-	rows, err := l.db.Query(ctx, query, args...)
+	start := time.Now()
+
+	phase := PhaseExecute
+	var count int64
+	conn := l.conn(ctx, name, query)
+	err := l.withSettings(ctx, conn, func(db DB) error {
+		rows, cached, err := l.cachedQuery(ctx, db, name, query, args)
+		if !cached {
+			rows, err = db.Query(ctx, query, args...)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Rows are closed before observing so that the command tag reports the row count
+		err = scanner(rows)
+		rows.Close()
+		count = rows.CommandTag().RowsAffected()
+		if err != nil {
+			phase = PhaseScan
+			return err
+		}
+
+		if err := rows.Err(); err != nil {
+			phase = PhaseRows
+			return err
+		}
+		return nil
+	})
+	l.observe(ctx, name, args, start, count, err)
+	if err != nil {
+		qerr := l.newQueryError(name, phase, query, args, err)
+		qerr.multiRow = true
+		return l.errorMap.apply(qerr)
+	}
+
+	l.invalidate(ctx, conn, name)
+	return nil
+}
+
+// cachedQuery returns the rows of a query annotated with "-- cache:" from the
+// result cache, running the query and storing its rows on a miss.
+// It reports false if caching is disabled, the query isn't annotated or db is a
+// transaction, in which case the caller runs the query itself.
+func (l *queryLoader) cachedQuery(ctx context.Context, db DB, name, query string, args []interface{}) (pgx.Rows, bool, error) {
+	if l.cache == nil {
+		return nil, false, nil
+	}
+	if _, isTx := db.(pgx.Tx); isTx {
+		return nil, false, nil
+	}
+
+	meta := l.querier.metadata(name)
+	ttl, ok := meta.cacheTTL()
+	if !ok {
+		return nil, false, nil
+	}
+
+	key, ok := cacheKey(name, args)
+	if !ok {
+		return nil, false, nil
+	}
+	if result, ok := l.cache.Get(ctx, key); ok {
+		return newCachedRows(result), true, nil
+	}
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, true, err
+	}
+	result, err := collectRows(rows)
 	if err != nil {
-		return fmt.Errorf("executing %s query: %w", name, err)
+		return nil, true, err
+	}
+
+	l.cache.Set(ctx, key, result, ttl, meta.tagList("tags"))
+	return newCachedRows(result), true, nil
+}
+
+// invalidate purges the cache entries tagged with the tables listed in the
+// query's "-- invalidates:" annotation. In a transaction started with
+// Connector.Begin the tags are purged when it commits.
+func (l *queryLoader) invalidate(ctx context.Context, db DB, name string) {
+	if l.cache == nil {
+		return
+	}
+	tags := l.querier.metadata(name).tagList("invalidates")
+	if len(tags) == 0 {
+		return
+	}
+
+	if tx, ok := db.(*cacheTx); ok {
+		tx.addTags(tags...)
+		return
 	}
-	defer rows.Close()
+	l.cache.Invalidate(ctx, tags...)
+}
+
+// wrapError wraps a failed query's error in a *QueryError and applies the
+// configured error mapping.
+func (l *queryLoader) wrapError(name string, phase QueryPhase, query string, args []interface{}, err error) error {
+	return l.errorMap.apply(l.newQueryError(name, phase, query, args, err))
+}
+
+// newQueryError builds the *QueryError for a failed query. Error positions are
+// only mapped to the .sql file when query is the file's SQL text, not the
+// SQL wrapped around it by Paginate or Explain.
+func (l *queryLoader) newQueryError(name string, phase QueryPhase, query string, args []interface{}, err error) *QueryError {
+	qerr := newQueryError(name, phase, query, l.querier.metadata(name), args, err)
+	if query != l.querier.get(name) {
+		qerr.Line, qerr.Column = 0, 0
+	}
+	return qerr
+}
 
-	if err := scanner(rows); err != nil {
-		return fmt.Errorf("scanning %s results: %w", name, err)
+// conn returns the connection that should run the named query.
+// A transaction stored in the context with WithTx takes precedence unless the
+// loader is already bound to a transaction. Otherwise cluster connectors route
+// read-only queries to replicas, and all others use l.db.
+func (l *queryLoader) conn(ctx context.Context, name, query string) DB {
+	if _, isTx := l.db.(pgx.Tx); !isTx {
+		if tx, ok := TxFromContext(ctx); ok {
+			return tx
+		}
 	}
 
-	return rows.Err()
+	if l.cluster == nil {
+		return l.db
+	}
+	return l.cluster.route(ctx, query, l.querier.metadata(name))
+}
+
+// observe reports a finished query to the slow query log, if one is configured.
+func (l *queryLoader) observe(ctx context.Context, name string, args []interface{}, start time.Time, rows int64, err error) {
+	if l.slowLog == nil {
+		return
+	}
+	l.slowLog.observe(ctx, name, l.querier.metadata(name), args, time.Since(start), rows, err)
 }
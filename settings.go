@@ -0,0 +1,123 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// setting is a single configuration parameter applied with set_config.
+type setting struct {
+	name  string
+	value string
+}
+
+// txBeginner is implemented by connections that can start a transaction,
+// such as *pgxpool.Pool.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// WithSettings returns a connector that applies the given configuration
+// parameters, for example "app.tenant_id" or "role", to every query it runs.
+//
+// The parameters are set with set_config(name, value, true), the equivalent of
+// SET LOCAL, so they only last until the end of the transaction. On a
+// transaction connector, or when ctx carries a transaction stored with WithTx,
+// they are set in that transaction before each query. On a pool connector each
+// query runs in a short transaction of its own. Queries with settings never use
+// the result cache.
+//
+// Settings add to those of c; on conflict the new value wins.
+//
+// Example:
+//
+//	tenantConn := conn.WithSettings(map[string]string{
+//	    "app.tenant_id": tenantID,
+//	    "role":          "tenant_user",
+//	})
+//
+//	// Row-level security policies see current_setting('app.tenant_id')
+//	err := tenantConn.QueryRows(ctx, "list_invoices", scanInvoices)
+func (c *Connector) WithSettings(settings map[string]string) *Connector {
+	merged := make(map[string]string, len(c.loader.settings)+len(settings))
+	for _, s := range c.loader.settings {
+		merged[s.name] = s.value
+	}
+	for name, value := range settings {
+		merged[name] = value
+	}
+
+	loader := *c.loader
+	loader.settings = make([]setting, 0, len(merged))
+	for name, value := range merged {
+		loader.settings = append(loader.settings, setting{name: name, value: value})
+	}
+	sort.Slice(loader.settings, func(i, j int) bool {
+		return loader.settings[i].name < loader.settings[j].name
+	})
+
+	return &Connector{
+		db:     c.db,
+		reader: c.reader,
+		loader: &loader,
+		schema: c.schema,
+	}
+}
+
+// withSettings runs fn with the loader's settings applied. Without settings,
+// fn runs directly on db. A transaction is started and committed around fn if
+// db isn't one already.
+func (l *queryLoader) withSettings(ctx context.Context, db DB, fn func(DB) error) error {
+	if len(l.settings) == 0 {
+		return fn(db)
+	}
+
+	if tx, ok := db.(pgx.Tx); ok {
+		if err := applySettings(ctx, tx, l.settings); err != nil {
+			return err
+		}
+		return fn(tx)
+	}
+
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return fmt.Errorf("applying settings: connection can't start a transaction")
+	}
+
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting transaction for settings: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := applySettings(ctx, tx, l.settings); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing settings transaction: %w", err)
+	}
+	return nil
+}
+
+// applySettings sets all parameters for the current transaction in a single statement.
+func applySettings(ctx context.Context, db DB, settings []setting) error {
+	calls := make([]string, len(settings))
+	args := make([]interface{}, 0, 2*len(settings))
+	for i, s := range settings {
+		calls[i] = fmt.Sprintf("set_config($%d, $%d, true)", 2*i+1, 2*i+2)
+		args = append(args, s.name, s.value)
+	}
+
+	if _, err := db.Exec(ctx, "SELECT "+strings.Join(calls, ", "), args...); err != nil {
+		return fmt.Errorf("applying settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package sqlreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// poolConn makes a mock behave like a pool: it can begin transactions but is not one.
+type poolConn struct {
+	DB
+	mock pgxmock.PgxConnIface
+}
+
+func (p poolConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.mock.Begin(ctx)
+}
+
+// Test that settings are applied in a short transaction on pool connectors
+func TestConnector_WithSettings(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{queries: &queryStore{
+		queries: map[string]string{"delete_invoice": "DELETE FROM invoices WHERE id = $1"},
+	}}
+	pool := poolConn{DB: mock, mock: mock}
+	conn := &Connector{db: pool, reader: reader, loader: reader.newLoader(pool)}
+
+	tenantConn := conn.
+		WithSettings(map[string]string{"app.tenant_id": "1", "role": "tenant_user"}).
+		WithSettings(map[string]string{"app.tenant_id": "42"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT set_config\\(\\$1, \\$2, true\\), set_config\\(\\$3, \\$4, true\\)").
+		WithArgs("app.tenant_id", "42", "role", "tenant_user").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("DELETE FROM invoices").
+		WithArgs(7).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM invoices").
+		WithArgs(8).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := tenantConn.Exec(context.Background(), "delete_invoice", 7); err != nil {
+		t.Errorf("Exec with settings returned an error: %v", err)
+	}
+	if err := conn.Exec(context.Background(), "delete_invoice", 8); err != nil {
+		t.Errorf("Exec without settings returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
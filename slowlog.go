@@ -0,0 +1,141 @@
+package sqlreader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// redactedArg replaces query arguments that must not be written to logs.
+const redactedArg = "[REDACTED]"
+
+// RedactFunc decides which query arguments may be logged.
+// It receives the query name and its arguments and returns the values to log,
+// typically a copy of args with sensitive entries replaced. Returning nil
+// omits the arguments from the log record entirely.
+type RedactFunc func(name string, args []interface{}) []interface{}
+
+// WithSlowQueryLog logs every Exec, QueryRow and QueryRows call that takes
+// longer than threshold.
+//
+// Each record carries the query name, duration, number of rows and the
+// SQLSTATE of a failed query. Arguments are only logged when the query
+// declares a "-- redact:" annotation or a RedactFunc is configured with
+// WithArgRedactor; otherwise they are left out so that PII never reaches the logs.
+//
+// The redact annotation accepts "none" (log every argument), "all" (log no
+// argument) or a comma-separated list of 1-based placeholder positions to mask:
+//
+//	-- name: get_user_by_email
+//	-- redact: 1
+//	SELECT id, name FROM users WHERE email = $1 AND active = $2
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithSlowQueryLog(slog.Default(), 200*time.Millisecond))
+func WithSlowQueryLog(logger *slog.Logger, threshold time.Duration) Option {
+	return func(r *SQLReader) {
+		r.slowLogger = logger
+		r.slowThreshold = threshold
+	}
+}
+
+// WithArgRedactor sets the global RedactFunc used by the slow query log for
+// queries that don't declare a "-- redact:" annotation.
+//
+// Example:
+//
+//	sqlreader.WithArgRedactor(func(name string, args []interface{}) []interface{} {
+//	    if strings.HasPrefix(name, "auth_") {
+//	        return nil
+//	    }
+//	    return args
+//	})
+func WithArgRedactor(fn RedactFunc) Option {
+	return func(r *SQLReader) {
+		r.redactor = fn
+	}
+}
+
+// slowQueryLog writes log records for queries exceeding a duration threshold.
+type slowQueryLog struct {
+	logger    *slog.Logger
+	threshold time.Duration
+	redact    RedactFunc
+}
+
+// observe logs the query if its execution took longer than the threshold.
+func (s *slowQueryLog) observe(ctx context.Context, name string, meta queryMeta, args []interface{}, elapsed time.Duration, rows int64, err error) {
+	if s == nil || elapsed < s.threshold {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("query", name),
+		slog.Duration("duration", elapsed),
+		slog.Int64("rows", rows),
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		attrs = append(attrs, slog.String("sqlstate", pgErr.Code))
+	}
+
+	if logged := s.loggableArgs(name, meta, args); logged != nil {
+		attrs = append(attrs, slog.Any("args", logged))
+	}
+
+	s.logger.LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+}
+
+// loggableArgs applies the query's redact annotation, or the global RedactFunc
+// when the query has none. It returns nil if no argument may be logged.
+func (s *slowQueryLog) loggableArgs(name string, meta queryMeta, args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	rule, ok := meta.annotation("redact")
+	if !ok {
+		if s.redact == nil {
+			return nil
+		}
+		return s.redact(name, append([]interface{}(nil), args...))
+	}
+
+	switch strings.ToLower(rule) {
+	case "none":
+		return args
+	case "", "all":
+		return nil
+	}
+
+	logged := append([]interface{}(nil), args...)
+	for _, field := range strings.Split(rule, ",") {
+		pos, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			// An unreadable rule must never leak arguments
+			return nil
+		}
+		if pos >= 1 && pos <= len(logged) {
+			logged[pos-1] = redactedArg
+		}
+	}
+
+	return logged
+}
+
+// rowCount returns the number of rows reported for a single-row query.
+// A failed scan, including pgx.ErrNoRows, counts as zero rows.
+func rowCount(err error) int64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
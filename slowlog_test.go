@@ -0,0 +1,130 @@
+package sqlreader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test parsing of query annotations
+func TestParseQueries_Annotations(t *testing.T) {
+	qs := &queryStore{queries: make(map[string]string)}
+	err := qs.parseQueries("users.sql", `-- name: find_user
+-- redact: 1,2
+-- readonly
+SELECT * FROM users WHERE email = $1 AND phone = $2`)
+	if err != nil {
+		t.Fatalf("parseQueries returned an error: %v", err)
+	}
+
+	if sql := qs.get("find_user"); sql != "SELECT * FROM users WHERE email = $1 AND phone = $2" {
+		t.Errorf("Unexpected SQL: %q", sql)
+	}
+
+	meta := qs.metadata("find_user")
+	if value, ok := meta.annotation("redact"); !ok || value != "1,2" {
+		t.Errorf("Expected redact annotation '1,2', got %q (declared: %v)", value, ok)
+	}
+	if _, ok := meta.annotation("readonly"); !ok {
+		t.Error("Expected readonly annotation to be declared")
+	}
+}
+
+// Test the argument redaction rules
+func TestSlowQueryLog_LoggableArgs(t *testing.T) {
+	args := []interface{}{"alice@example.com", "555-1234", true}
+
+	tests := []struct {
+		name     string
+		rule     string
+		declared bool
+		redact   RedactFunc
+		expected []interface{}
+	}{
+		{name: "no annotation, no redactor", expected: nil},
+		{name: "annotation none", rule: "none", declared: true, expected: args},
+		{name: "annotation all", rule: "all", declared: true, expected: nil},
+		{name: "annotation positions", rule: "1, 2", declared: true, expected: []interface{}{redactedArg, redactedArg, true}},
+		{name: "invalid annotation", rule: "email", declared: true, expected: nil},
+		{
+			name: "global redactor",
+			redact: func(name string, args []interface{}) []interface{} {
+				args[0] = redactedArg
+				return args
+			},
+			expected: []interface{}{redactedArg, "555-1234", true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			meta := queryMeta{annotations: map[string]string{}}
+			if tc.declared {
+				meta.annotations["redact"] = tc.rule
+			}
+
+			s := &slowQueryLog{redact: tc.redact}
+			logged := s.loggableArgs("find_user", meta, args)
+			if len(logged) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, logged)
+			}
+			for i := range logged {
+				if logged[i] != tc.expected[i] {
+					t.Errorf("Argument %d: expected %v, got %v", i, tc.expected[i], logged[i])
+				}
+			}
+		})
+	}
+
+	if args[0] != "alice@example.com" {
+		t.Error("Redaction modified the caller's arguments")
+	}
+}
+
+// Test that slow queries are logged through the query loader
+func TestQueryLoader_SlowQueryLog(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	var buf bytes.Buffer
+	loader := &queryLoader{
+		db: mock,
+		querier: &queryStore{
+			queries: map[string]string{"update_email": "UPDATE users SET email = $1 WHERE id = $2"},
+			meta:    map[string]queryMeta{"update_email": {annotations: map[string]string{"redact": "1"}}},
+		},
+		slowLog: &slowQueryLog{logger: slog.New(slog.NewJSONHandler(&buf, nil))},
+	}
+
+	mock.ExpectExec("UPDATE users SET email").
+		WithArgs("alice@example.com", 7).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := loader.exec(context.Background(), "update_email", "alice@example.com", 7); err != nil {
+		t.Fatalf("exec returned an error: %v", err)
+	}
+
+	var record struct {
+		Msg   string        `json:"msg"`
+		Query string        `json:"query"`
+		Rows  int64         `json:"rows"`
+		Args  []interface{} `json:"args"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode log record %q: %v", buf.String(), err)
+	}
+
+	if record.Msg != "slow query" || record.Query != "update_email" || record.Rows != 1 {
+		t.Errorf("Unexpected log record: %s", buf.String())
+	}
+	if len(record.Args) != 2 || record.Args[0] != redactedArg || record.Args[1] != float64(7) {
+		t.Errorf("Expected redacted args, got %v", record.Args)
+	}
+}
@@ -36,6 +36,8 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -49,14 +51,41 @@ type SQLReader struct {
 	queriesFS     embed.FS
 	queriesDir    string
 	migrationsDir string
+
+	slowLogger    *slog.Logger
+	slowThreshold time.Duration
+	redactor      RedactFunc
+	errorMapping  *ErrorMapping
+
+	replicaSelection ReplicaSelection
+	cache            Cache
+	cursorKey        []byte
+	tenantQuery      string
+	tenantFallback   []string
+
+	migrationLogger      *slog.Logger
+	migrationLockKey     string
+	migrationLockTimeout time.Duration
+	migrationTxMode      MigrationTxMode
+	noTxCommits          bool
+	goMigrations         []migration
+
+	migrationTable       string
+	migrationTableSchema string
+	appVersion           string
+	outOfOrder           OutOfOrderPolicy
 }
 
+// Option configures optional SQLReader behaviour. Options are passed to New.
+type Option func(*SQLReader)
+
 // New creates a new SQLReader instance.
 //
 // Parameters:
 //   - queriesFS: An embedded filesystem containing SQL queries and migrations
 //   - queriesDir: The directory in the filesystem containing SQL query files
 //   - migrationsDir: The directory in the filesystem containing migration files
+//   - opts: Optional settings such as WithSlowQueryLog
 //
 // Returns a new SQLReader instance or an error if initialization fails.
 //
@@ -66,18 +95,65 @@ type SQLReader struct {
 //	var fs embed.FS
 //
 //	reader, err := sqlreader.New(fs, "sql", "migrations")
-func New(queriesFS embed.FS, queriesDir, migrationsDir string) (*SQLReader, error) {
+func New(queriesFS embed.FS, queriesDir, migrationsDir string, opts ...Option) (*SQLReader, error) {
 	queries, err := newQueryStore(queriesFS, queriesDir)
 	if err != nil {
 		return nil, fmt.Errorf("initializing query store: %w", err)
 	}
 
-	return &SQLReader{
+	r := &SQLReader{
 		queries:       queries,
 		queriesFS:     queriesFS,
 		queriesDir:    queriesDir,
 		migrationsDir: migrationsDir,
-	}, nil
+
+		tenantFallback: []string{"public"},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.migrationTableSchema != "" && r.tenantQuery != "" {
+		return nil, fmt.Errorf("WithMigrationSchema can't be combined with WithTenantSchemas: each tenant schema tracks its migrations in a table of its own")
+	}
+	if r.cursorKey == nil {
+		r.cursorKey = newCursorKey()
+	}
+
+	return r, nil
+}
+
+// newLoader creates a query loader for the given connection using the
+// reader's queries and options.
+func (r *SQLReader) newLoader(db DB) *queryLoader {
+	loader := &queryLoader{
+		db:        db,
+		querier:   r.queries,
+		errorMap:  r.errorMapping,
+		cache:     r.cache,
+		cursorKey: r.cursorKey,
+	}
+	if r.slowLogger != nil {
+		loader.slowLog = &slowQueryLog{
+			logger:    r.slowLogger,
+			threshold: r.slowThreshold,
+			redact:    r.redactor,
+		}
+	}
+
+	return loader
+}
+
+// newMigrator creates a migration manager for the given connection using the
+// reader's migrations and options.
+func (r *SQLReader) newMigrator(db DB) *migrationManager {
+	m := newMigrationManager(db, r.queriesFS, r.migrationsDir)
+	m.funcs = r.goMigrations
+	m.table = r.migrationTableName()
+	m.schema = r.migrationTableSchema
+	m.appVersion = r.appVersion
+	m.outOfOrder = r.outOfOrder
+	m.logger = r.migrationLog()
+	return m
 }
 
 // GetSQL retrieves an SQL query by name.
@@ -94,12 +170,18 @@ func (r *SQLReader) GetSQL(name string) string {
 	return r.queries.get(name)
 }
 
+// QueryNames returns the names of all loaded queries in sorted order.
+func (r *SQLReader) QueryNames() []string {
+	return r.queries.names()
+}
+
 // Connector wraps a database connection with query execution methods.
 // It provides a convenient API for executing queries and managing migrations.
 type Connector struct {
-	db     dbConn
+	db     DB
 	reader *SQLReader
 	loader *queryLoader
+	schema string // Tenant schema set by ForTenant
 }
 
 // ConnectPool creates a new connector from a database connection pool.
@@ -114,15 +196,10 @@ type Connector struct {
 //
 //	conn := reader.ConnectPool(pool)
 func (r *SQLReader) ConnectPool(pool *pgxpool.Pool) *Connector {
-	loader := &queryLoader{
-		db:      pool,
-		querier: r.queries,
-	}
-
 	return &Connector{
 		db:     pool,
 		reader: r,
-		loader: loader,
+		loader: r.newLoader(pool),
 	}
 }
 
@@ -140,15 +217,28 @@ func (r *SQLReader) ConnectPool(pool *pgxpool.Pool) *Connector {
 //	// Execute queries in transaction
 //	tx.Commit(context.Background())
 func (r *SQLReader) ConnectTx(tx pgx.Tx) *Connector {
-	loader := &queryLoader{
-		db:      tx,
-		querier: r.queries,
+	return &Connector{
+		db:     tx,
+		reader: r,
+		loader: r.newLoader(tx),
 	}
+}
 
+// Connect creates a new connector from any connection implementing DB.
+//
+// Use ConnectPool or ConnectTx for real databases; Connect is meant for
+// connections such as test doubles. Migrations, Listen and advisory locks
+// require a *pgxpool.Pool or pgx.Tx and fail on other connections.
+//
+// Example:
+//
+//	rec := sqlreadertest.NewRecorder(reader)
+//	conn := reader.Connect(rec)
+func (r *SQLReader) Connect(db DB) *Connector {
 	return &Connector{
-		db:     tx,
+		db:     db,
 		reader: r,
-		loader: loader,
+		loader: r.newLoader(db),
 	}
 }
 
@@ -217,7 +307,7 @@ func (c *Connector) QueryRows(ctx context.Context, name string, scanner func(pgx
 // This method is called automatically by Migrate and Rollback, but you can call it
 // explicitly if you need to ensure the migrations table exists without applying migrations.
 func (c *Connector) InitiateMigration(ctx context.Context) error {
-	c.reader.migrations = newMigrationManager(c.db, c.reader.queriesFS, c.reader.migrationsDir)
+	c.reader.migrations = c.reader.newMigrator(c.db)
 	return c.reader.migrations.Initialize(ctx)
 }
 
@@ -225,6 +315,7 @@ func (c *Connector) InitiateMigration(ctx context.Context) error {
 //
 // This method automatically starts a transaction if one isn't already in progress,
 // applies all pending migrations, and commits the transaction if successful.
+// With WithTenantSchemas or ForTenant, migrations are applied to each tenant schema.
 //
 // Example:
 //
@@ -233,52 +324,33 @@ func (c *Connector) InitiateMigration(ctx context.Context) error {
 //	    log.Fatal(err)
 //	}
 func (c *Connector) Migrate(ctx context.Context) error {
-	// Initialize migration manager if needed
-	if c.reader.migrations == nil {
-		if err := c.InitiateMigration(ctx); err != nil {
-			return err
-		}
-	}
-
-	// Check if we're already in a transaction
-	_, isTx := c.db.(pgx.Tx)
-	if isTx {
-		// Already in a transaction, just migrate
-		return c.reader.migrations.Migrate(ctx)
-	}
-
-	// Need to start a transaction for migration
-	conn, ok := c.db.(*pgxpool.Pool)
-	if !ok {
-		return fmt.Errorf("unexpected connection type, expected *pgxpool.Pool")
-	}
-
-	tx, err := conn.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("starting transaction for migration: %w", err)
-	}
-
-	// Create a new migration manager with the transaction
-	txMigrations := newMigrationManager(tx, c.reader.queriesFS, c.reader.migrationsDir)
-
-	// Apply migrations
-	if err := txMigrations.Migrate(ctx); err != nil {
-		tx.Rollback(ctx)
-		return err
-	}
-
-	// Commit the transaction
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("committing migration transaction: %w", err)
-	}
+	return c.runMigrations(ctx, "migration", (*migrationManager).Migrate)
+}
 
-	return nil
+// MigrateTo applies the pending migrations up to and including the given version.
+//
+// Like Migrate, all migrations are applied in a single transaction. It returns an
+// error wrapping ErrMigrationNotFound if no migration file has that version, and
+// an error if that version is applied and newer ones are too; use RollbackTo to
+// go back.
+//
+// Example:
+//
+//	// Phase 1: apply the initial schema only
+//	if err := conn.MigrateTo(ctx, 1); err != nil {
+//	    log.Fatal(err)
+//	}
+func (c *Connector) MigrateTo(ctx context.Context, version int64) error {
+	return c.runMigrations(ctx, "migration", func(m *migrationManager, ctx context.Context) error {
+		return m.MigrateTo(ctx, version)
+	})
 }
 
 // Rollback reverts the last applied migration.
 //
 // This method automatically starts a transaction if one isn't already in progress,
 // reverts the last migration, and commits the transaction if successful.
+// With WithTenantSchemas or ForTenant, the last migration of each tenant schema is reverted.
 //
 // Example:
 //
@@ -287,20 +359,109 @@ func (c *Connector) Migrate(ctx context.Context) error {
 //	    log.Fatal(err)
 //	}
 func (c *Connector) Rollback(ctx context.Context) error {
+	return c.runMigrations(ctx, "rollback", (*migrationManager).Rollback)
+}
+
+// RollbackTo reverts the applied migrations newer than the given version, newest
+// first, leaving that version as the last applied migration. Use RollbackAll to
+// revert every migration.
+//
+// All migrations are reverted in a single transaction. It returns an error
+// wrapping ErrMigrationNotFound if no migration file has that version, which
+// may be 0.
+//
+// Example:
+//
+//	if err := conn.RollbackTo(ctx, 3); err != nil {
+//	    log.Fatal(err)
+//	}
+func (c *Connector) RollbackTo(ctx context.Context, version int64) error {
+	return c.runMigrations(ctx, "rollback", func(m *migrationManager, ctx context.Context) error {
+		return m.RollbackTo(ctx, version)
+	})
+}
+
+// RollbackAll reverts every applied migration, newest first, in a single
+// transaction.
+//
+// Example:
+//
+//	// Tear down the schema of an integration test database
+//	if err := conn.RollbackAll(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+func (c *Connector) RollbackAll(ctx context.Context) error {
+	return c.runMigrations(ctx, "rollback", (*migrationManager).RollbackAll)
+}
+
+// RollbackSteps reverts the last n applied migrations, newest first, in a single
+// transaction. If fewer than n migrations are applied, all of them are reverted.
+//
+// Example:
+//
+//	// Revert the last two migrations
+//	if err := conn.RollbackSteps(ctx, 2); err != nil {
+//	    log.Fatal(err)
+//	}
+func (c *Connector) RollbackSteps(ctx context.Context, n int) error {
+	return c.runMigrations(ctx, "rollback", func(m *migrationManager, ctx context.Context) error {
+		return m.RollbackSteps(ctx, n)
+	})
+}
+
+// Repair re-stamps the recorded names and checksums of applied migrations from
+// the current migration files.
+//
+// Use it after deliberately editing an applied migration, such as fixing a
+// comment, so that Migrate stops returning *ErrMigrationModified. Records
+// stamped before checksums were recorded are filled in as well.
+//
+// Example:
+//
+//	if err := conn.Repair(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+func (c *Connector) Repair(ctx context.Context) error {
+	return c.runMigrations(ctx, "repair", (*migrationManager).Repair)
+}
+
+// runMigrations runs fn on a migration manager bound to a transaction, holding
+// the migration lock if one is configured with WithMigrationLock.
+//
+// Tenant schemas are migrated one by one. Otherwise a transaction connector
+// runs fn in its transaction, and a pool connector starts a transaction that is
+// committed if fn succeeds. Only pool connectors can run migrations declared
+// with the "-- sqlreader:no-transaction" directive. The kind ("migration", "rollback" or "repair") is
+// used in error messages.
+func (c *Connector) runMigrations(ctx context.Context, kind string, fn func(*migrationManager, context.Context) error) error {
+	return c.withMigrationLock(ctx, func(ctx context.Context) error {
+		return c.migrateInTx(ctx, kind, fn)
+	})
+}
+
+// migrateInTx runs fn as described for runMigrations, without locking.
+func (c *Connector) migrateInTx(ctx context.Context, kind string, fn func(*migrationManager, context.Context) error) error {
+	schemas, err := c.migrationSchemas(ctx)
+	if err != nil {
+		return err
+	}
+	if schemas != nil {
+		return c.migrateSchemas(ctx, schemas, fn)
+	}
+
+	// Initialize migration manager if needed
 	if c.reader.migrations == nil {
 		if err := c.InitiateMigration(ctx); err != nil {
 			return err
 		}
 	}
 
-	// Check if we're already in a transaction
-	_, isTx := c.db.(pgx.Tx)
-	if isTx {
-		// Already in a transaction, just rollback
-		return c.reader.migrations.Rollback(ctx)
+	// Already in a transaction, just migrate
+	if tx, isTx := c.db.(pgx.Tx); isTx {
+		return fn(c.reader.newMigrator(tx), ctx)
 	}
 
-	// Need to start a transaction for rollback
+	// Need to start a transaction for migration
 	conn, ok := c.db.(*pgxpool.Pool)
 	if !ok {
 		return fmt.Errorf("unexpected connection type, expected *pgxpool.Pool")
@@ -308,21 +469,21 @@ func (c *Connector) Rollback(ctx context.Context) error {
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("starting transaction for rollback: %w", err)
+		return fmt.Errorf("starting transaction for %s: %w", kind, err)
 	}
 
-	// Create a new migration manager with the transaction
-	txMigrations := newMigrationManager(tx, c.reader.queriesFS, c.reader.migrationsDir)
-
-	// Apply rollback
-	if err := txMigrations.Rollback(ctx); err != nil {
-		tx.Rollback(ctx)
+	// Migrations that run outside a transaction replace the manager's transaction
+	m := c.reader.newMigrator(tx)
+	m.pool = conn
+	m.perMigration = c.reader.migrationTxMode == TransactionPerMigration
+	m.splitTx = c.reader.noTxCommits
+	if err := fn(m, ctx); err != nil {
+		m.db.(pgx.Tx).Rollback(ctx)
 		return err
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("committing rollback transaction: %w", err)
+	if err := m.db.(pgx.Tx).Commit(ctx); err != nil {
+		return fmt.Errorf("committing %s transaction: %w", kind, err)
 	}
 
 	return nil
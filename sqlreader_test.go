@@ -75,7 +75,7 @@ WHERE
 			qs := &queryStore{
 				queries: make(map[string]string),
 			}
-			err := qs.parseQueries(tc.content)
+			err := qs.parseQueries("test.sql", tc.content)
 			if err != nil {
 				t.Fatalf("parseQueries returned an error: %v", err)
 			}
@@ -545,7 +545,7 @@ func TestMigrations_WithPgxMock(t *testing.T) {
 
 		// Expect recording of first migration
 		mock.ExpectExec("INSERT INTO schema_migrations").
-			WithArgs(1, "create_users", pgxmock.AnyArg()).
+			WithArgs(int64(1), "create_users", pgxmock.AnyArg()).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		// Second migration: create_posts
@@ -554,7 +554,7 @@ func TestMigrations_WithPgxMock(t *testing.T) {
 
 		// Expect recording of second migration
 		mock.ExpectExec("INSERT INTO schema_migrations").
-			WithArgs(2, "create_posts", pgxmock.AnyArg()).
+			WithArgs(int64(2), "create_posts", pgxmock.AnyArg()).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		// Third migration: create_comments
@@ -563,7 +563,7 @@ func TestMigrations_WithPgxMock(t *testing.T) {
 
 		// Expect recording of third migration
 		mock.ExpectExec("INSERT INTO schema_migrations").
-			WithArgs(3, "create_comments", pgxmock.AnyArg()).
+			WithArgs(int64(3), "create_comments", pgxmock.AnyArg()).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		// Fourth migration: add_email_to_users
@@ -572,7 +572,7 @@ func TestMigrations_WithPgxMock(t *testing.T) {
 
 		// Expect recording of fourth migration
 		mock.ExpectExec("INSERT INTO schema_migrations").
-			WithArgs(4, "add_email_to_users", pgxmock.AnyArg()).
+			WithArgs(int64(4), "add_email_to_users", pgxmock.AnyArg()).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		// Expect transaction to commit
@@ -596,10 +596,10 @@ func TestMigrations_WithPgxMock(t *testing.T) {
 		// This time return all previously applied migrations
 		mock2.ExpectQuery("SELECT version, name, applied_at.*FROM schema_migrations.*").
 			WillReturnRows(pgxmock.NewRows([]string{"version", "name", "applied_at"}).
-				AddRow(1, "create_users", "2023-01-01T00:00:00Z").
-				AddRow(2, "create_posts", "2023-01-01T00:00:00Z").
-				AddRow(3, "create_comments", "2023-01-01T00:00:00Z").
-				AddRow(4, "add_email_to_users", "2023-01-01T00:00:00Z"))
+				AddRow(int64(1), "create_users", "2023-01-01T00:00:00Z").
+				AddRow(int64(2), "create_posts", "2023-01-01T00:00:00Z").
+				AddRow(int64(3), "create_comments", "2023-01-01T00:00:00Z").
+				AddRow(int64(4), "add_email_to_users", "2023-01-01T00:00:00Z"))
 
 		// Expect transaction to commit as there's nothing to do
 		mock2.ExpectCommit()
@@ -622,10 +622,10 @@ func TestMigrations_WithPgxMock(t *testing.T) {
 		// Return all previously applied migrations
 		mock3.ExpectQuery("SELECT version, name, applied_at.*FROM schema_migrations.*").
 			WillReturnRows(pgxmock.NewRows([]string{"version", "name", "applied_at"}).
-				AddRow(1, "create_users", "2023-01-01T00:00:00Z").
-				AddRow(2, "create_posts", "2023-01-01T00:00:00Z").
-				AddRow(3, "create_comments", "2023-01-01T00:00:00Z").
-				AddRow(4, "add_email_to_users", "2023-01-01T00:00:00Z"))
+				AddRow(int64(1), "create_users", "2023-01-01T00:00:00Z").
+				AddRow(int64(2), "create_posts", "2023-01-01T00:00:00Z").
+				AddRow(int64(3), "create_comments", "2023-01-01T00:00:00Z").
+				AddRow(int64(4), "add_email_to_users", "2023-01-01T00:00:00Z"))
 
 		// Expect transaction to commit as there's nothing to do
 		mock3.ExpectCommit()
@@ -761,7 +761,7 @@ func (m *testMigrationManager) LoadMigrations() ([]migration, error) {
 	}, nil
 }
 
-func (m *testMigrationManager) GetAppliedMigrations(ctx context.Context) (map[int]migration, error) {
+func (m *testMigrationManager) GetAppliedMigrations(ctx context.Context) (map[int64]migration, error) {
 	rows, err := m.db.Query(ctx, `
 		SELECT version, name, applied_at
 		FROM schema_migrations
@@ -772,7 +772,7 @@ func (m *testMigrationManager) GetAppliedMigrations(ctx context.Context) (map[in
 	}
 	defer rows.Close()
 
-	applied := make(map[int]migration)
+	applied := make(map[int64]migration)
 	for rows.Next() {
 		var mig migration
 		var appliedAtStr string
@@ -0,0 +1,106 @@
+package sqlreadertest
+
+import (
+	"context"
+
+	sqlreader "github.com/NodePath81/pgx-sqlreader"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Mock is a pgxmock pool whose expectations are keyed by query name.
+//
+// The SQL of each expectation is resolved from the SQLReader and matched
+// exactly, so tests don't copy SQL text into regular expressions and keep
+// passing when a query is edited.
+//
+// Use Connect rather than passing the mock to SQLReader.Connect: the pgxmock
+// pool also implements pgx.Tx, which would turn the connector into a
+// transaction connector that bypasses the cache and joins no context
+// transactions.
+//
+// Example:
+//
+//	mock, err := sqlreadertest.NewMock(reader)
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	defer mock.Close()
+//
+//	mock.ExpectNamedExec("create_user").
+//	    WithArgs("john", "John Doe").
+//	    WillReturnResult(pgxmock.NewResult("INSERT", 1))
+//	mock.ExpectNamed("get_user_by_username").
+//	    WithArgs("john").
+//	    WillReturnRows(pgxmock.NewRows([]string{"id", "username", "name"}).AddRow(1, "john", "John Doe"))
+//
+//	conn := mock.Connect()
+//	// exercise the code under test with conn
+//
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//	    t.Error(err)
+//	}
+type Mock struct {
+	pgxmock.PgxPoolIface
+	reader *sqlreader.SQLReader
+}
+
+// NewMock creates a mock for the named queries of reader.
+// SQL is matched by exact string comparison.
+func NewMock(reader *sqlreader.SQLReader) (*Mock, error) {
+	pool, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(pgxmock.QueryMatcherEqual))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mock{
+		PgxPoolIface: pool,
+		reader:       reader,
+	}, nil
+}
+
+// ExpectNamed expects the named query to be run with QueryRow or QueryRows.
+// Panics if the query is not found.
+func (m *Mock) ExpectNamed(name string) *pgxmock.ExpectedQuery {
+	return m.ExpectQuery(m.reader.GetSQL(name))
+}
+
+// ExpectNamedExec expects the named query to be run with Exec.
+// Panics if the query is not found.
+func (m *Mock) ExpectNamedExec(name string) *pgxmock.ExpectedExec {
+	return m.ExpectExec(m.reader.GetSQL(name))
+}
+
+// Connect creates a connector that runs its queries against the mock.
+//
+// The connector behaves like a pool connector: queries are cached, WithTx
+// transactions are joined and transactions started with Connector.Begin
+// become mock expectations. Migrations, Listen and advisory locks need a
+// *pgxpool.Pool and fail on it.
+func (m *Mock) Connect() *sqlreader.Connector {
+	return m.reader.Connect(poolConn{m.PgxPoolIface})
+}
+
+// poolConn exposes the pgxmock pool as a non-transaction connection that can
+// start transactions. The pool itself also implements pgx.Tx, which would
+// make the connector take the transaction paths.
+type poolConn struct {
+	pool pgxmock.PgxPoolIface
+}
+
+func (c poolConn) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return c.pool.Exec(ctx, sql, args...)
+}
+
+func (c poolConn) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return c.pool.Query(ctx, sql, args...)
+}
+
+func (c poolConn) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return c.pool.QueryRow(ctx, sql, args...)
+}
+
+func (c poolConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return c.pool.Begin(ctx)
+}
@@ -0,0 +1,96 @@
+package sqlreadertest
+
+import (
+	"context"
+	"testing"
+
+	sqlreader "github.com/NodePath81/pgx-sqlreader"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test expectations keyed by query name
+func TestMock_ExpectNamed(t *testing.T) {
+	reader := newTestReader(t)
+	mock, err := NewMock(reader)
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectNamedExec("create_user").
+		WithArgs("john", "John Doe").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectNamed("get_user_by_username").
+		WithArgs("john").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "username", "name"}).AddRow(1, "john", "John Doe"))
+
+	conn := mock.Connect()
+	ctx := context.Background()
+
+	if err := conn.Exec(ctx, "create_user", "john", "John Doe"); err != nil {
+		t.Errorf("Exec returned an error: %v", err)
+	}
+
+	var id int
+	var username, name string
+	err = conn.QueryRow(ctx, "get_user_by_username", func(row pgx.Row) error {
+		return row.Scan(&id, &username, &name)
+	}, "john")
+	if err != nil {
+		t.Errorf("QueryRow returned an error: %v", err)
+	}
+	if id != 1 || name != "John Doe" {
+		t.Errorf("Unexpected row: %d, %s, %s", id, username, name)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that an unknown query name panics like SQLReader.GetSQL
+func TestMock_ExpectNamedUnknown(t *testing.T) {
+	mock, err := NewMock(newTestReader(t))
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer mock.Close()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("ExpectNamed did not panic on nonexistent query")
+		}
+	}()
+	mock.ExpectNamed("nonexistent_query")
+}
+
+// Test that the mock connector behaves like a pool connector, not a transaction
+func TestMock_ConnectLikePool(t *testing.T) {
+	mock, err := NewMock(newTestReader(t))
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	conn := mock.Connect()
+	ctx := context.Background()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin returned an error: %v", err)
+	}
+	if conn.FromContext(sqlreader.WithTx(ctx, tx)) == conn {
+		t.Error("Expected the mock connector to join the transaction in the context")
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Errorf("Rollback returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package sqlreadertest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	sqlreader "github.com/NodePath81/pgx-sqlreader"
+)
+
+// placeholderPattern matches positional query parameters such as $1.
+var placeholderPattern = regexp.MustCompile(`\$\d+`)
+
+// PlanGuard checks the plans of named queries against stored baselines.
+//
+// Baselines record the shape of each plan (node types, relations and indexes)
+// and its estimated total cost. A check fails when the shape changes or the
+// cost changes by more than CostThreshold. Plans are produced with EXPLAIN
+// without ANALYZE, so queries are never executed.
+//
+// Run the check against a seeded local database, and run the tests with
+// -sqlreadertest.update to create or accept new baselines.
+//
+// Example:
+//
+//	func TestQueryPlans(t *testing.T) {
+//	    guard := sqlreadertest.PlanGuard{
+//	        Args: map[string][]interface{}{
+//	            "get_user_by_username": {"john"},
+//	        },
+//	        Skip: []string{"create_user"},
+//	    }
+//	    guard.Check(t, reader, reader.ConnectPool(pool))
+//	}
+type PlanGuard struct {
+	// Dir is the directory holding the baseline files. Defaults to "testdata/plans".
+	Dir string
+
+	// Args holds the arguments used to explain each query.
+	// Queries with placeholders must have an entry unless they are skipped.
+	Args map[string][]interface{}
+
+	// CostThreshold is the allowed relative change of the estimated total cost.
+	// Defaults to 0.2 (20%).
+	CostThreshold float64
+
+	// Skip lists queries that are not checked.
+	Skip []string
+}
+
+// Check explains every named query of reader on conn and compares the plans
+// with their baselines, reporting each query as a subtest.
+func (g PlanGuard) Check(t *testing.T, reader *sqlreader.SQLReader, conn *sqlreader.Connector) {
+	t.Helper()
+
+	dir := g.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "plans")
+	}
+	threshold := g.CostThreshold
+	if threshold == 0 {
+		threshold = 0.2
+	}
+
+	skip := make(map[string]bool, len(g.Skip))
+	for _, name := range g.Skip {
+		skip[name] = true
+	}
+
+	for _, name := range reader.QueryNames() {
+		if skip[name] {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			args, ok := g.Args[name]
+			if !ok && placeholderPattern.MatchString(reader.GetSQL(name)) {
+				t.Fatalf("query %s has placeholders; add arguments to PlanGuard.Args or skip it", name)
+			}
+
+			plan, err := conn.Explain(context.Background(), name, sqlreader.ExplainOptions{}, args...)
+			if err != nil {
+				t.Fatalf("explaining %s: %v", name, err)
+			}
+
+			checkPlan(t, filepath.Join(dir, name+".plan"), plan, threshold)
+		})
+	}
+}
+
+// checkPlan compares a plan with the baseline at path, or rewrites it in update mode.
+func checkPlan(t *testing.T, path string, plan *sqlreader.Plan, threshold float64) {
+	t.Helper()
+
+	actual := FormatPlan(plan)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating baseline directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("writing baseline: %v", err)
+		}
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading baseline (run with -sqlreadertest.update to create it): %v", err)
+	}
+
+	expectedShape, expectedCost, err := parsePlanBaseline(string(content))
+	if err != nil {
+		t.Fatalf("parsing baseline %s: %v", path, err)
+	}
+
+	if shape := planShape(plan); shape != expectedShape {
+		t.Errorf("plan shape changed (run with -sqlreadertest.update to accept):\n--- baseline\n%s--- actual\n%s", expectedShape, shape)
+	}
+
+	cost := plan.Root.TotalCost
+	if change := relativeChange(expectedCost, cost); change > threshold {
+		t.Errorf("estimated cost changed by %.0f%% from %.2f to %.2f, more than the allowed %.0f%%",
+			change*100, expectedCost, cost, threshold*100)
+	}
+}
+
+// FormatPlan renders the shape and estimated cost of a plan as stored in baseline files:
+//
+//	Nested Loop
+//	  Index Scan on users using users_username_key
+//	  Seq Scan on posts
+//	cost: 16.50
+func FormatPlan(plan *sqlreader.Plan) string {
+	return planShape(plan) + fmt.Sprintf("cost: %.2f\n", plan.Root.TotalCost)
+}
+
+// planShape renders the plan nodes as an indented tree, one node per line.
+func planShape(plan *sqlreader.Plan) string {
+	var b strings.Builder
+	var write func(node *sqlreader.PlanNode, depth int)
+	write = func(node *sqlreader.PlanNode, depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString(node.NodeType)
+		if node.RelationName != "" {
+			b.WriteString(" on " + node.RelationName)
+		}
+		if node.IndexName != "" {
+			b.WriteString(" using " + node.IndexName)
+		}
+		b.WriteString("\n")
+
+		for i := range node.Plans {
+			write(&node.Plans[i], depth+1)
+		}
+	}
+	write(&plan.Root, 0)
+	return b.String()
+}
+
+// parsePlanBaseline splits a baseline file into its shape and cost.
+func parsePlanBaseline(content string) (string, float64, error) {
+	content = strings.TrimRight(content, "\n")
+	i := strings.LastIndex(content, "\n")
+	shape, costLine := content[:i+1], content[i+1:]
+
+	value, ok := strings.CutPrefix(costLine, "cost: ")
+	if !ok || i < 0 {
+		return "", 0, fmt.Errorf("missing cost line")
+	}
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid cost %q: %w", value, err)
+	}
+
+	return shape, cost, nil
+}
+
+// relativeChange returns the change from baseline to actual relative to baseline.
+func relativeChange(baseline, actual float64) float64 {
+	if baseline == 0 {
+		if actual == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return math.Abs(actual-baseline) / baseline
+}
@@ -0,0 +1,63 @@
+package sqlreadertest
+
+import (
+	"testing"
+
+	sqlreader "github.com/NodePath81/pgx-sqlreader"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test checking query plans against the stored baselines
+func TestPlanGuard_Check(t *testing.T) {
+	reader := newTestReader(t)
+	mock, err := NewMock(reader)
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectQuery("EXPLAIN (FORMAT JSON)\n" + reader.GetSQL("get_user_by_username")).
+		WithArgs("john").
+		WillReturnRows(pgxmock.NewRows([]string{"QUERY PLAN"}).AddRow(
+			`[{"Plan": {"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_username_key", "Total Cost": 8.3}}]`))
+
+	guard := PlanGuard{
+		Args: map[string][]interface{}{"get_user_by_username": {"john"}},
+		Skip: []string{"create_user"},
+	}
+	guard.Check(t, reader, mock.Connect())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test the baseline file format
+func TestFormatPlan(t *testing.T) {
+	plan := &sqlreader.Plan{Root: sqlreader.PlanNode{
+		NodeType:  "Nested Loop",
+		TotalCost: 16.5,
+		Plans: []sqlreader.PlanNode{
+			{NodeType: "Index Scan", RelationName: "users", IndexName: "users_username_key"},
+			{NodeType: "Seq Scan", RelationName: "posts"},
+		},
+	}}
+
+	expected := "Nested Loop\n  Index Scan on users using users_username_key\n  Seq Scan on posts\ncost: 16.50\n"
+	formatted := FormatPlan(plan)
+	if formatted != expected {
+		t.Errorf("Format mismatch:\nExpected: %q\nActual: %q", expected, formatted)
+	}
+
+	shape, cost, err := parsePlanBaseline(formatted)
+	if err != nil {
+		t.Fatalf("parsePlanBaseline returned an error: %v", err)
+	}
+	if shape != planShape(plan) || cost != 16.5 {
+		t.Errorf("Unexpected baseline: %q, %v", shape, cost)
+	}
+
+	if change := relativeChange(10, 13); change < 0.29 || change > 0.31 {
+		t.Errorf("Expected a 30%% change, got %v", change)
+	}
+}
@@ -0,0 +1,174 @@
+// Package sqlreadertest provides test doubles for code built on sqlreader.
+//
+// A Recorder stands in for the database connection of a Connector. It records
+// every statement the Connector sends together with the name of the query it
+// came from, and answers with canned results registered per query name:
+//
+//	rec := sqlreadertest.NewRecorder(reader)
+//	rec.On("get_user_by_username").ReturnRows([]string{"id", "username", "name"},
+//	    []interface{}{1, "john", "John Doe"})
+//
+//	conn := reader.Connect(rec)
+//	// exercise the code under test with conn
+//
+//	rec.Snapshot(t, "testdata/users.golden")
+package sqlreadertest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	sqlreader "github.com/NodePath81/pgx-sqlreader"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Call is a statement recorded by a Recorder.
+type Call struct {
+	Name string        // The name of the query, empty if the SQL matches no named query
+	SQL  string        // The SQL text sent by the connector
+	Args []interface{} // The arguments sent with the SQL
+}
+
+// Recorder implements sqlreader.DB without a database.
+// It is safe for concurrent use.
+type Recorder struct {
+	queries map[string]string // SQL text to query name
+
+	mu      sync.Mutex
+	calls   []Call
+	results map[string]*Result
+}
+
+// NewRecorder creates a recorder that resolves statements to the named queries of reader.
+func NewRecorder(reader *sqlreader.SQLReader) *Recorder {
+	queries := make(map[string]string)
+	for _, name := range reader.QueryNames() {
+		queries[reader.GetSQL(name)] = name
+	}
+
+	return &Recorder{
+		queries: queries,
+		results: make(map[string]*Result),
+	}
+}
+
+// On returns the canned result for the named query, creating it if needed.
+// Queries without a registered result succeed and return no rows.
+func (r *Recorder) On(name string) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result, ok := r.results[name]
+	if !ok {
+		result = &Result{}
+		r.results[name] = result
+	}
+	return result
+}
+
+// Calls returns the statements recorded so far, in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Reset discards the recorded statements. Registered results are kept.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// Exec records the statement and returns the canned command tag or error.
+func (r *Recorder) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	result := r.record(sql, args)
+	if result.err != nil {
+		return pgconn.CommandTag{}, result.err
+	}
+	return pgconn.NewCommandTag(result.tag), nil
+}
+
+// Query records the statement and returns the canned rows or error.
+func (r *Recorder) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	result := r.record(sql, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return newRows(result), nil
+}
+
+// QueryRow records the statement and returns the first canned row. Scanning
+// the row returns the canned error, or pgx.ErrNoRows if there are no rows.
+func (r *Recorder) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	result := r.record(sql, args)
+	if result.err != nil {
+		return errRow{err: result.err}
+	}
+	return newRows(result)
+}
+
+// record stores a call and returns a snapshot of the result for its query.
+func (r *Recorder) record(sql string, args []interface{}) Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := r.resolve(sql)
+	r.calls = append(r.calls, Call{Name: name, SQL: sql, Args: append([]interface{}(nil), args...)})
+
+	if result, ok := r.results[name]; ok && name != "" {
+		return *result
+	}
+	return Result{}
+}
+
+// resolve returns the name of the query whose SQL was sent. Statements that
+// wrap a named query, such as pagination or EXPLAIN, resolve to the longest
+// named query they contain.
+func (r *Recorder) resolve(sql string) string {
+	if name, ok := r.queries[sql]; ok {
+		return name
+	}
+
+	var best, bestSQL string
+	for querySQL, name := range r.queries {
+		if len(querySQL) > len(bestSQL) && strings.Contains(sql, querySQL) {
+			best, bestSQL = name, querySQL
+		}
+	}
+	return best
+}
+
+// Result is the canned response of a named query.
+type Result struct {
+	tag     string
+	columns []string
+	rows    [][]interface{}
+	err     error
+}
+
+// ReturnRows makes the query return the given rows. Values are assigned to
+// scan destinations of a compatible Go type.
+func (res *Result) ReturnRows(columns []string, rows ...[]interface{}) *Result {
+	res.columns = columns
+	res.rows = rows
+	res.tag = fmt.Sprintf("SELECT %d", len(rows))
+	return res
+}
+
+// ReturnResult makes the query return the given command tag, such as "INSERT 0 1".
+func (res *Result) ReturnResult(tag string) *Result {
+	res.tag = tag
+	return res
+}
+
+// ReturnError makes the query fail with err.
+func (res *Result) ReturnError(err error) *Result {
+	res.err = err
+	return res
+}
+
+var _ sqlreader.DB = (*Recorder)(nil)
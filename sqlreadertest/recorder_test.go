@@ -0,0 +1,74 @@
+package sqlreadertest
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"testing"
+
+	sqlreader "github.com/NodePath81/pgx-sqlreader"
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed testdata/sql/*.sql
+var testFS embed.FS
+
+func newTestReader(t *testing.T) *sqlreader.SQLReader {
+	t.Helper()
+
+	reader, err := sqlreader.New(testFS, "testdata/sql", "testdata/migrations")
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	return reader
+}
+
+// Test recording of calls and canned results
+func TestRecorder(t *testing.T) {
+	reader := newTestReader(t)
+	rec := NewRecorder(reader)
+	conn := reader.Connect(rec)
+	ctx := context.Background()
+
+	rec.On("get_user_by_username").ReturnRows([]string{"id", "username", "name"},
+		[]interface{}{int32(1), "john", "John Doe"})
+	errDuplicate := errors.New("duplicate")
+	rec.On("create_user").ReturnError(errDuplicate)
+
+	if err := conn.Exec(ctx, "create_user", "john", "John Doe"); !errors.Is(err, errDuplicate) {
+		t.Errorf("Expected canned error, got %v", err)
+	}
+
+	var id int64
+	var username, name string
+	err := conn.QueryRow(ctx, "get_user_by_username", func(row pgx.Row) error {
+		return row.Scan(&id, &username, &name)
+	}, "john")
+	if err != nil {
+		t.Fatalf("QueryRow returned an error: %v", err)
+	}
+	if id != 1 || username != "john" || name != "John Doe" {
+		t.Errorf("Unexpected row: %d, %s, %s", id, username, name)
+	}
+
+	calls := rec.Calls()
+	if len(calls) != 2 || calls[0].Name != "create_user" || calls[1].Name != "get_user_by_username" {
+		t.Fatalf("Unexpected calls: %+v", calls)
+	}
+
+	rec.Snapshot(t, "testdata/recorder.golden")
+}
+
+// Test that queries without canned rows return pgx.ErrNoRows
+func TestRecorder_NoRows(t *testing.T) {
+	reader := newTestReader(t)
+	conn := reader.Connect(NewRecorder(reader))
+
+	var id int
+	err := conn.QueryRow(context.Background(), "get_user_by_username", func(row pgx.Row) error {
+		return row.Scan(&id)
+	}, "nobody")
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Expected pgx.ErrNoRows, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package sqlreadertest
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// rows replays canned Go values through the pgx.Rows and pgx.Row interfaces.
+type rows struct {
+	result Result
+	pos    int
+	closed bool
+	err    error
+}
+
+// newRows creates rows positioned before the first canned row.
+func newRows(result Result) *rows {
+	return &rows{result: result}
+}
+
+func (r *rows) Close() {
+	r.closed = true
+}
+
+func (r *rows) Err() error {
+	return r.err
+}
+
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(r.result.tag)
+}
+
+func (r *rows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(r.result.columns))
+	for i, column := range r.result.columns {
+		fields[i] = pgconn.FieldDescription{Name: column, Format: pgtype.TextFormatCode}
+	}
+	return fields
+}
+
+func (r *rows) Next() bool {
+	if r.closed || r.err != nil || r.pos >= len(r.result.rows) {
+		r.closed = true
+		return false
+	}
+	r.pos++
+	return true
+}
+
+// Scan assigns the current row to dest. When called before Next, as pgx.Row
+// does, it scans the first row and returns pgx.ErrNoRows if there is none.
+func (r *rows) Scan(dest ...interface{}) error {
+	if r.pos == 0 {
+		if !r.Next() {
+			return pgx.ErrNoRows
+		}
+		defer r.Close()
+	}
+
+	values := r.result.rows[r.pos-1]
+	if len(dest) != len(values) {
+		return fmt.Errorf("number of field descriptions must equal number of destinations, got %d and %d", len(values), len(dest))
+	}
+
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		if err := assign(d, values[i]); err != nil {
+			r.err = fmt.Errorf("can't scan into dest[%d]: %w", i, err)
+			return r.err
+		}
+	}
+	return nil
+}
+
+func (r *rows) Values() ([]interface{}, error) {
+	return append([]interface{}(nil), r.result.rows[r.pos-1]...), nil
+}
+
+func (r *rows) RawValues() [][]byte {
+	values := r.result.rows[r.pos-1]
+	raw := make([][]byte, len(values))
+	for i, value := range values {
+		if value != nil {
+			raw[i] = []byte(fmt.Sprint(value))
+		}
+	}
+	return raw
+}
+
+func (r *rows) Conn() *pgx.Conn {
+	return nil
+}
+
+// assign stores value in the pointer dest, converting between compatible types.
+func assign(dest, value interface{}) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	target = target.Elem()
+
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	source := reflect.ValueOf(value)
+	switch {
+	case source.Type().AssignableTo(target.Type()):
+		target.Set(source)
+	case target.Kind() == reflect.Pointer && source.Type().AssignableTo(target.Type().Elem()):
+		ptr := reflect.New(target.Type().Elem())
+		ptr.Elem().Set(source)
+		target.Set(ptr)
+	case source.Type().ConvertibleTo(target.Type()) && source.Kind() != reflect.String && target.Kind() != reflect.String:
+		target.Set(source.Convert(target.Type()))
+	default:
+		return fmt.Errorf("cannot assign %T to %s", value, target.Type())
+	}
+	return nil
+}
+
+// errRow is a pgx.Row whose Scan fails with a canned error.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
@@ -0,0 +1,74 @@
+package sqlreadertest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites golden files instead of comparing against them:
+//
+//	go test ./... -sqlreadertest.update
+var update = flag.Bool("sqlreadertest.update", false, "update sqlreadertest golden files")
+
+// Format renders recorded calls as text, one block per call:
+//
+//	-- get_user_by_username
+//	SELECT id, username, name
+//	FROM users
+//	WHERE username = $1
+//	-- args: "john"
+func Format(calls []Call) string {
+	var b strings.Builder
+	for i, call := range calls {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		name := call.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		fmt.Fprintf(&b, "-- %s\n%s\n", name, strings.TrimSpace(call.SQL))
+
+		if len(call.Args) > 0 {
+			args := make([]string, len(call.Args))
+			for j, arg := range call.Args {
+				args[j] = fmt.Sprintf("%#v", arg)
+			}
+			fmt.Fprintf(&b, "-- args: %s\n", strings.Join(args, ", "))
+		}
+	}
+	return b.String()
+}
+
+// Snapshot compares the recorded calls with the golden file at path and fails
+// the test on any difference, so changes to the emitted SQL show up in diffs.
+//
+// Run the tests with -sqlreadertest.update to create or rewrite the golden file.
+func (r *Recorder) Snapshot(t testing.TB, path string) {
+	t.Helper()
+
+	actual := Format(r.Calls())
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("writing golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -sqlreadertest.update to create it): %v", err)
+	}
+
+	if string(expected) != actual {
+		t.Errorf("recorded SQL does not match %s (run with -sqlreadertest.update to accept):\n--- expected\n%s\n--- actual\n%s", path, expected, actual)
+	}
+}
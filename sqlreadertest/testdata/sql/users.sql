@@ -0,0 +1,8 @@
+-- name: create_user
+INSERT INTO users (username, name)
+VALUES ($1, $2)
+
+-- name: get_user_by_username
+SELECT id, username, name
+FROM users
+WHERE username = $1
@@ -0,0 +1,230 @@
+package sqlreader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WithTenantSchemas configures the named query that lists the schemas of all
+// tenants. The query must return a single text column.
+//
+// When set, Migrate and Rollback operate on every tenant schema instead of the
+// default search_path, each schema tracking its own schema_migrations table.
+//
+//	-- name: list_tenant_schemas
+//	SELECT schema_name FROM tenants ORDER BY schema_name
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithTenantSchemas("list_tenant_schemas"))
+func WithTenantSchemas(queryName string) Option {
+	return func(r *SQLReader) {
+		r.tenantQuery = queryName
+	}
+}
+
+// WithTenantFallbackSchemas sets the schemas that follow the tenant schema in
+// the search_path of connectors returned by ForTenant. Unqualified names
+// missing from the tenant schema, such as extension functions or shared lookup
+// tables, are resolved in these schemas. The default is "public"; call it
+// without schemas to search the tenant schema alone.
+//
+// Tenant migrations don't use the fallback schemas, so unqualified DDL can't
+// change a shared table the tenant schema lacks.
+//
+// Example:
+//
+//	reader, err := sqlreader.New(fs, "sql", "migrations",
+//	    sqlreader.WithTenantFallbackSchemas("shared", "public"))
+func WithTenantFallbackSchemas(schemas ...string) Option {
+	return func(r *SQLReader) {
+		r.tenantFallback = schemas
+	}
+}
+
+// tenantSearchPath returns the quoted search_path for a tenant schema,
+// followed by the fallback schemas.
+func (r *SQLReader) tenantSearchPath(schema string) string {
+	path := pgx.Identifier{schema}.Sanitize()
+	for _, fallback := range r.tenantFallback {
+		path += ", " + pgx.Identifier{fallback}.Sanitize()
+	}
+	return path
+}
+
+// ForTenant returns a connector that runs every named query with search_path
+// set to the given schema, followed by the schemas set with
+// WithTenantFallbackSchemas ("public" by default).
+//
+// The schemas are quoted as identifiers and set transaction-locally, as
+// described for WithSettings. Migrate and Rollback on the returned connector
+// only operate on the tenant schema, and return an error if the reader sets
+// WithMigrationSchema.
+//
+// Example:
+//
+//	tenantConn := conn.ForTenant("tenant_acme")
+//	err := tenantConn.QueryRows(ctx, "list_invoices", scanInvoices)
+func (c *Connector) ForTenant(schema string) *Connector {
+	tenant := c.WithSettings(map[string]string{"search_path": c.reader.tenantSearchPath(schema)})
+	tenant.schema = schema
+	return tenant
+}
+
+// migrationSchemas returns the schemas that Migrate and Rollback operate on:
+// the connector's tenant schema, every schema listed by the tenant query, or
+// nil to use the default search_path.
+func (c *Connector) migrationSchemas(ctx context.Context) ([]string, error) {
+	if c.schema != "" {
+		return []string{c.schema}, nil
+	}
+	if c.reader.tenantQuery == "" {
+		return nil, nil
+	}
+
+	var schemas []string
+	err := c.loader.queryRows(ctx, c.reader.tenantQuery, func(rows pgx.Rows) error {
+		for rows.Next() {
+			var schema string
+			if err := rows.Scan(&schema); err != nil {
+				return err
+			}
+			schemas = append(schemas, schema)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing tenant schemas: %w", err)
+	}
+
+	return schemas, nil
+}
+
+// tenantStatus reads the migration status of the connector's tenant schema in
+// a transaction with search_path set to that schema alone, as migrateSchema
+// does. On a transaction connector the original search_path is restored
+// afterwards; otherwise the transaction is rolled back.
+func (c *Connector) tenantStatus(ctx context.Context) ([]MigrationInfo, error) {
+	if c.reader.migrationTableSchema != "" {
+		return nil, fmt.Errorf("reading migration status: the migrations table schema set with WithMigrationSchema would be shared by every tenant")
+	}
+
+	status := func(tx pgx.Tx) ([]MigrationInfo, error) {
+		m := c.reader.newMigrator(tx)
+		m.searchPath = pgx.Identifier{c.schema}.Sanitize()
+		if err := m.setSearchPath(ctx); err != nil {
+			return nil, fmt.Errorf("setting search_path to %s: %w", c.schema, err)
+		}
+		return m.Status(ctx)
+	}
+
+	if tx, isTx := c.db.(pgx.Tx); isTx {
+		var searchPath string
+		if err := tx.QueryRow(ctx, "SELECT current_setting('search_path')").Scan(&searchPath); err != nil {
+			return nil, fmt.Errorf("reading search_path: %w", err)
+		}
+
+		info, err := status(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec(ctx, "SELECT set_config('search_path', $1, true)", searchPath); err != nil {
+			return nil, fmt.Errorf("restoring search_path: %w", err)
+		}
+		return info, nil
+	}
+
+	beginner, ok := c.db.(txBeginner)
+	if !ok {
+		return nil, fmt.Errorf("reading migration status: connection can't start a transaction")
+	}
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction for schema %s: %w", c.schema, err)
+	}
+	defer tx.Rollback(ctx)
+
+	return status(tx)
+}
+
+// migrateSchemas runs fn on a migration manager for each schema, with
+// search_path set to that schema. Missing schemas are created.
+//
+// On a pool connector each schema is migrated in its own transaction, or in a
+// transaction per migration with TransactionPerMigration. On a transaction
+// connector all schemas are migrated in that transaction, and the original
+// search_path is restored afterwards.
+func (c *Connector) migrateSchemas(ctx context.Context, schemas []string, fn func(*migrationManager, context.Context) error) error {
+	if c.reader.migrationTableSchema != "" {
+		return fmt.Errorf("migrating tenant schemas: the migrations table schema set with WithMigrationSchema would be shared by every tenant")
+	}
+
+	if tx, isTx := c.db.(pgx.Tx); isTx {
+		var searchPath string
+		if err := tx.QueryRow(ctx, "SELECT current_setting('search_path')").Scan(&searchPath); err != nil {
+			return fmt.Errorf("reading search_path: %w", err)
+		}
+
+		for _, schema := range schemas {
+			if err := c.migrateSchema(ctx, c.reader.newMigrator(tx), schema, fn); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(ctx, "SELECT set_config('search_path', $1, true)", searchPath); err != nil {
+			return fmt.Errorf("restoring search_path: %w", err)
+		}
+		return nil
+	}
+
+	pool, ok := c.db.(*pgxpool.Pool)
+	if !ok {
+		return fmt.Errorf("unexpected connection type, expected *pgxpool.Pool")
+	}
+
+	for _, schema := range schemas {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("starting transaction for schema %s: %w", schema, err)
+		}
+
+		// Transactions begun after a commit get the schema's search_path again
+		m := c.reader.newMigrator(tx)
+		m.pool = pool
+		m.perMigration = c.reader.migrationTxMode == TransactionPerMigration
+		if err := c.migrateSchema(ctx, m, schema, fn); err != nil {
+			m.db.(pgx.Tx).Rollback(ctx)
+			return err
+		}
+
+		if err := m.db.(pgx.Tx).Commit(ctx); err != nil {
+			return fmt.Errorf("committing migration transaction for schema %s: %w", schema, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateSchema creates the schema if needed, points search_path at it alone
+// and runs fn. Unqualified names in migrations only resolve in the tenant
+// schema; shared objects must be qualified.
+func (c *Connector) migrateSchema(ctx context.Context, m *migrationManager, schema string, fn func(*migrationManager, context.Context) error) error {
+	if _, err := m.db.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		return fmt.Errorf("creating schema %s: %w", schema, err)
+	}
+
+	m.searchPath = pgx.Identifier{schema}.Sanitize()
+	if err := m.setSearchPath(ctx); err != nil {
+		return fmt.Errorf("setting search_path to %s: %w", schema, err)
+	}
+
+	if err := fn(m, ctx); err != nil {
+		return fmt.Errorf("schema %s: %w", schema, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package sqlreader
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// Test that tenant connectors set a quoted, transaction-local search_path
+func TestConnector_ForTenant(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fallback   []string
+		searchPath string
+	}{
+		{name: "DefaultFallback", fallback: []string{"public"}, searchPath: `"Tenant ""A""", "public"`},
+		{name: "SharedFallback", fallback: []string{"shared", "public"}, searchPath: `"Tenant ""A""", "shared", "public"`},
+		{name: "NoFallback", searchPath: `"Tenant ""A"""`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("Failed to create mock connection: %v", err)
+			}
+			defer mock.Close(context.Background())
+
+			reader := &SQLReader{
+				queries: &queryStore{
+					queries: map[string]string{"count_invoices": "SELECT count(*) FROM invoices"},
+				},
+				tenantFallback: tc.fallback,
+			}
+			conn := &Connector{db: mock, reader: reader, loader: reader.newLoader(mock)}
+
+			mock.ExpectExec("SELECT set_config\\(\\$1, \\$2, true\\)").
+				WithArgs("search_path", tc.searchPath).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mock.ExpectExec("SELECT count").
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+			tenantConn := conn.ForTenant(`Tenant "A"`)
+			if err := tenantConn.Exec(context.Background(), "count_invoices"); err != nil {
+				t.Errorf("Exec returned an error: %v", err)
+			}
+			if tenantConn.schema != `Tenant "A"` {
+				t.Errorf("Expected tenant schema to be recorded, got %q", tenantConn.schema)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+// Test that New defaults the tenant fallback to public and the option replaces it
+func TestWithTenantFallbackSchemas(t *testing.T) {
+	reader, err := New(testMigrationsFS, "testdata/migrations", "testdata/migrations")
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	if path := reader.tenantSearchPath("acme"); path != `"acme", "public"` {
+		t.Errorf("Expected default fallback to public, got %s", path)
+	}
+
+	reader, err = New(testMigrationsFS, "testdata/migrations", "testdata/migrations", WithTenantFallbackSchemas())
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	if path := reader.tenantSearchPath("acme"); path != `"acme"` {
+		t.Errorf("Expected no fallback, got %s", path)
+	}
+}
+
+// Test that Rollback iterates over the schemas listed by the tenant query
+func TestConnector_RollbackTenants(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{
+		queries: &queryStore{
+			queries: map[string]string{"list_tenant_schemas": "SELECT schema_name FROM tenants"},
+		},
+		migrationsDir: "migrations",
+		tenantQuery:   "list_tenant_schemas",
+	}
+	conn := &Connector{db: mock, reader: reader, loader: reader.newLoader(mock)}
+
+	mock.ExpectQuery("SELECT schema_name FROM tenants").
+		WillReturnRows(pgxmock.NewRows([]string{"schema_name"}).AddRow("acme").AddRow("globex"))
+	mock.ExpectQuery("SELECT current_setting\\('search_path'\\)").
+		WillReturnRows(pgxmock.NewRows([]string{"search_path"}).AddRow(`"$user", public`))
+	for _, schema := range []string{"acme", "globex"} {
+		mock.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "` + schema + `"`).
+			WillReturnResult(pgxmock.NewResult("CREATE SCHEMA", 0))
+		mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+			WithArgs(`"` + schema + `"`).
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		mock.ExpectQuery("SELECT version, name, applied_at.*FROM schema_migrations.*").
+			WillReturnRows(migrationRows())
+	}
+	mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+		WithArgs(`"$user", public`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	if err := conn.Rollback(context.Background()); err != nil {
+		t.Errorf("Rollback returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that tenant migrations search the tenant schema alone, without the fallback schemas
+func TestConnector_MigrateTenantSearchPath(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader, err := New(testMigrationsFS, "testdata/migrations", "testdata/migrations")
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	conn := reader.Connect(mock).ForTenant("acme")
+
+	mock.ExpectQuery("SELECT current_setting\\('search_path'\\)").
+		WillReturnRows(pgxmock.NewRows([]string{"search_path"}).AddRow(`"$user", public`))
+	mock.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "acme"`).
+		WillReturnResult(pgxmock.NewResult("CREATE SCHEMA", 0))
+	mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+		WithArgs(`"acme"`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(appliedRows(t, 1, 2, 3))
+	mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+		WithArgs(`"$user", public`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	if err := conn.Migrate(context.Background()); err != nil {
+		t.Errorf("Migrate returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that tenant migrations refuse migrations that must run outside a transaction
+func TestConnector_MigrateTenantNoTransaction(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader := &SQLReader{
+		queries:       &queryStore{},
+		queriesFS:     testNoTxMigrationsFS,
+		migrationsDir: "testdata/notx",
+	}
+	conn := (&Connector{db: mock, reader: reader, loader: reader.newLoader(mock)}).ForTenant("acme")
+
+	mock.ExpectQuery("SELECT current_setting\\('search_path'\\)").
+		WillReturnRows(pgxmock.NewRows([]string{"search_path"}).AddRow(`"$user", public`))
+	mock.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "acme"`).
+		WillReturnResult(pgxmock.NewResult("CREATE SCHEMA", 0))
+	mock.ExpectExec("SELECT set_config\\('search_path', \\$1, true\\)").
+		WithArgs(`"acme"`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, name, applied_at").WillReturnRows(migrationRows())
+
+	err = conn.Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "schema acme: migration 2 must run outside a transaction, which needs a pool connector and isn't supported for tenant schemas") {
+		t.Errorf("Expected a no-transaction error for the tenant schema, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Test that a migrations table schema is refused together with tenant schemas
+func TestWithMigrationSchemaTenants(t *testing.T) {
+	_, err := New(testMigrationsFS, "testdata/migrations", "testdata/migrations",
+		WithMigrationSchema("billing"), WithTenantSchemas("list_tenant_schemas"))
+	if err == nil || !strings.Contains(err.Error(), "WithMigrationSchema can't be combined with WithTenantSchemas") {
+		t.Errorf("Expected New to refuse a migration schema with tenant schemas, got %v", err)
+	}
+
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer mock.Close(context.Background())
+
+	reader, err := New(testMigrationsFS, "testdata/migrations", "testdata/migrations", WithMigrationSchema("billing"))
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	err = reader.Connect(mock).ForTenant("acme").Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "would be shared by every tenant") {
+		t.Errorf("Expected ForTenant migrations to refuse a migration schema, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
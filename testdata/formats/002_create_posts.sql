@@ -0,0 +1,15 @@
+-- Posts imported from goose
+-- +goose Up
+-- +goose StatementBegin
+CREATE FUNCTION touch_post() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+CREATE TABLE posts (id SERIAL PRIMARY KEY, updated_at TIMESTAMPTZ);
+
+-- +goose Down
+DROP TABLE posts;
+DROP FUNCTION touch_post();
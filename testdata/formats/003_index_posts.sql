@@ -0,0 +1,5 @@
+-- +migrate Up notransaction
+CREATE INDEX CONCURRENTLY posts_updated_at_idx ON posts (updated_at);
+
+-- +migrate Down
+DROP INDEX CONCURRENTLY posts_updated_at_idx;
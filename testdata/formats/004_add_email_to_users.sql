@@ -0,0 +1,5 @@
+-- Downgrade note: the email column is dropped with its data
+ALTER TABLE users ADD COLUMN email TEXT;
+
+-- Down
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,4 @@
+CREATE TABLE users (id SERIAL PRIMARY KEY, name TEXT);
+
+-- Down
+DROP TABLE users;
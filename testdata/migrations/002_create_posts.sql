@@ -0,0 +1,4 @@
+CREATE TABLE posts (id SERIAL PRIMARY KEY, title TEXT, user_id INTEGER REFERENCES users(id));
+
+-- Down
+DROP TABLE posts;
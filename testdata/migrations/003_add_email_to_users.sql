@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN email TEXT;
+
+-- Down
+ALTER TABLE users DROP COLUMN email;
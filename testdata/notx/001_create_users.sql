@@ -0,0 +1,4 @@
+CREATE TABLE users (id SERIAL PRIMARY KEY, email TEXT);
+
+-- Down
+DROP TABLE users;
@@ -0,0 +1,7 @@
+-- sqlreader:no-transaction
+CREATE INDEX CONCURRENTLY users_email_idx ON users (email);
+CREATE INDEX CONCURRENTLY users_lower_email_idx ON users (lower(email));
+
+-- Down
+DROP INDEX CONCURRENTLY users_lower_email_idx;
+DROP INDEX CONCURRENTLY users_email_idx;
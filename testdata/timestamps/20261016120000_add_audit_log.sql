@@ -0,0 +1,4 @@
+CREATE TABLE audit_log (id BIGSERIAL PRIMARY KEY, entry TEXT);
+
+-- Down
+DROP TABLE audit_log;
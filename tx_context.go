@@ -0,0 +1,61 @@
+package sqlreader
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// txKey is the context key under which WithTx stores a transaction.
+type txKey struct{}
+
+// WithTx returns a context carrying the given transaction.
+//
+// Pool and cluster connectors run their queries in the transaction stored in the
+// context, so a service-level transaction flows into repositories without
+// changing their signatures. Connectors created with ConnectTx keep using
+// their own transaction.
+//
+// Example:
+//
+//	tx, err := pool.Begin(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer tx.Rollback(ctx)
+//
+//	ctx = sqlreader.WithTx(ctx, tx)
+//	if err := users.Create(ctx, "john"); err != nil { // uses tx
+//	    return err
+//	}
+//	return tx.Commit(ctx)
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction stored in ctx by WithTx, if any.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok && tx != nil
+}
+
+// FromContext returns a connector bound to the transaction stored in ctx.
+// If ctx carries no transaction, or c is already bound to a transaction,
+// c is returned unchanged.
+//
+// Example:
+//
+//	txConn := conn.FromContext(ctx)
+//	err := txConn.Migrate(ctx)
+func (c *Connector) FromContext(ctx context.Context) *Connector {
+	if _, isTx := c.db.(pgx.Tx); isTx {
+		return c
+	}
+
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return c
+	}
+
+	return c.reader.ConnectTx(tx)
+}
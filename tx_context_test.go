@@ -0,0 +1,59 @@
+package sqlreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// nonTxConn hides the pgx.Tx methods of a mock so that it behaves like a pool.
+type nonTxConn struct {
+	DB
+}
+
+// Test that pool connectors pick up the transaction stored in the context
+func TestQueryLoader_ContextTx(t *testing.T) {
+	pool, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock connection: %v", err)
+	}
+	defer pool.Close(context.Background())
+
+	tx, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("Failed to create mock transaction: %v", err)
+	}
+	defer tx.Close(context.Background())
+
+	reader := &SQLReader{queries: &queryStore{
+		queries: map[string]string{"create_user": "INSERT INTO users (name) VALUES ($1)"},
+	}}
+	conn := &Connector{db: nonTxConn{pool}, reader: reader, loader: reader.newLoader(nonTxConn{pool})}
+
+	pool.ExpectExec("INSERT INTO users").WithArgs("John").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	tx.ExpectExec("INSERT INTO users").WithArgs("Jane").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := conn.Exec(context.Background(), "create_user", "John"); err != nil {
+		t.Errorf("Exec without transaction returned an error: %v", err)
+	}
+
+	ctx := WithTx(context.Background(), tx)
+	if err := conn.Exec(ctx, "create_user", "Jane"); err != nil {
+		t.Errorf("Exec with transaction returned an error: %v", err)
+	}
+
+	if err := pool.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled pool expectations: %v", err)
+	}
+	if err := tx.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled transaction expectations: %v", err)
+	}
+
+	if txConn := conn.FromContext(ctx); txConn.db != tx {
+		t.Error("Expected FromContext to return a connector bound to the context transaction")
+	}
+	if same := conn.FromContext(context.Background()); same != conn {
+		t.Error("Expected FromContext without transaction to return the connector unchanged")
+	}
+}